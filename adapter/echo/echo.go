@@ -0,0 +1,48 @@
+// Package echo renders Echo responses with wutrender, so c.Render goes through wutrender layouts and partials:
+//
+//	e := echo.New()
+//	e.Renderer = wutecho.New(wutrender.New(wutrender.Options{
+//		Directory: "templates",
+//		Funcs:     []template.FuncMap{wutecho.Funcs},
+//	}))
+//
+//	e.GET("/login", func(c echo.Context) error {
+//		return c.Render(200, "sessions/new", data)
+//	})
+package echo
+
+import (
+	"github.com/8protons/wutrender"
+	"github.com/labstack/echo/v4"
+	"html/template"
+	"io"
+)
+
+// Funcs declare the echo helper returning echo.Context of the current request: {{ (echo).Get "user" }}.
+// Add them to Options.Funcs of the renderer, Render sets the context per request.
+var Funcs = template.FuncMap{
+	"echo": func() echo.Context {
+		return nil
+	},
+}
+
+// Renderer is echo.Renderer backed by a wutrender Renderer
+type Renderer struct {
+	Renderer *wutrender.Renderer
+}
+
+// New creates Renderer for r
+func New(r *wutrender.Renderer) *Renderer {
+	return &Renderer{Renderer: r}
+}
+
+// Render renders the html template name (without format, e.g. "sessions/new") with its layout into w
+func (r *Renderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	tmpl := r.Renderer.Copy().SetRequest(c.Request()).SetFuncs(template.FuncMap{
+		"echo": func() echo.Context {
+			return c
+		},
+	})
+
+	return tmpl.RenderTo(w, "html", name, data)
+}
@@ -0,0 +1,48 @@
+package echo
+
+import (
+	"github.com/8protons/wutrender"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Renderer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "sessions"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sessions", "new.html.tmpl"), []byte(`{{ . }} {{ (echo).Path }} {{ (echo).Get "user" }}`), 0644)
+
+	e := echo.New()
+	e.Renderer = New(wutrender.New(wutrender.Options{
+		Directory: dir,
+		Layout:    "layout",
+		Funcs:     []template.FuncMap{Funcs},
+	}))
+	e.GET("/login", func(c echo.Context) error {
+		c.Set("user", "bob")
+		return c.Render(200, "sessions/new", "Sign in")
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return c.Render(200, "sessions/missing", nil)
+	})
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/login", nil)
+	e.ServeHTTP(rw, req)
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Header().Get("Content-Type"), echo.MIMETextHTMLCharsetUTF8)
+	assert.Equal(t, rw.Body.String(), "<main>Sign in /login bob</main>")
+
+	rw = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	e.ServeHTTP(rw, req)
+	assert.Equal(t, rw.Code, 500)
+}
@@ -0,0 +1,63 @@
+// Package gin renders Gin responses with wutrender, so c.HTML goes through wutrender layouts and partials:
+//
+//	router := gin.Default()
+//	router.HTMLRender = wutgin.New(wutrender.New(wutrender.Options{Directory: "templates"}))
+//
+//	router.GET("/login", func(c *gin.Context) {
+//		c.HTML(200, "sessions/new", gin.H{"Title": "Sign in"})
+//	})
+package gin
+
+import (
+	"github.com/8protons/wutrender"
+	"github.com/gin-gonic/gin/render"
+	"net/http"
+)
+
+// HTMLRender is gin's render.HTMLRender backed by a wutrender Renderer
+type HTMLRender struct {
+	Renderer *wutrender.Renderer
+}
+
+// HTML renders one template for a gin response
+type HTML struct {
+	Template *wutrender.TemplateCopy
+	Name     string
+	Data     interface{}
+}
+
+// New creates HTMLRender for r
+func New(r *wutrender.Renderer) *HTMLRender {
+	return &HTMLRender{Renderer: r}
+}
+
+// Instance renders name (without format, e.g. "sessions/new") with a new copy of the renderer
+func (h *HTMLRender) Instance(name string, data interface{}) render.Render {
+	return HTML{
+		Template: h.Renderer.Copy(),
+		Name:     name,
+		Data:     data,
+	}
+}
+
+// Render writes the template with its layout. On errors nothing is written but the 500 status,
+// gin records the returned error in c.Errors.
+func (h HTML) Render(w http.ResponseWriter) error {
+	h.WriteContentType(w)
+
+	buf, err := h.Template.HTML(h.Name, h.Data)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	defer wutrender.ReleaseBuffer(buf)
+
+	_, err = w.Write(buf.Bytes())
+
+	return err
+}
+
+// WriteContentType sets the html content type
+func (h HTML) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set(wutrender.ContentType, wutrender.ContentHTML)
+}
@@ -0,0 +1,44 @@
+package gin
+
+import (
+	"errors"
+	"github.com/8protons/wutrender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_HTMLRender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.HTMLRender = New(wutrender.New(wutrender.Options{
+		Directory: "../../fixtures",
+		Layout:    "base/layout",
+	}))
+
+	var renderErr error
+	router.GET("/hello", func(c *gin.Context) {
+		c.HTML(201, "base/hello", "gin")
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.HTML(200, "base/missing", nil)
+		renderErr = c.Errors.Last()
+	})
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/hello", nil)
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get("Content-Type"), wutrender.ContentHTML)
+	assert.Equal(t, rw.Body.String(), "head\n<div>Hello gin</div>\nfoot")
+
+	rw = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	router.ServeHTTP(rw, req)
+	assert.Equal(t, rw.Code, 500)
+	assert.Equal(t, rw.Body.String(), "")
+	assert.True(t, errors.Is(renderErr, wutrender.ErrTemplateNotFound))
+}
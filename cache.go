@@ -0,0 +1,153 @@
+package wutrender
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned by RedisClient.Get for missing keys
+var ErrCacheMiss = errors.New("wutrender: cache miss")
+
+// CacheStore keeps partials rendered by cachedPartial. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the fragment of key, ok is false if it's missing or expired
+	Get(key string) (html template.HTML, ok bool, err error)
+	// Set stores the fragment of key for ttl
+	Set(key string, html template.HTML, ttl time.Duration) error
+	// Delete removes the fragment of key, missing keys are not an error
+	Delete(key string) error
+}
+
+// MemoryStore is the in-process CacheStore used by default
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]cachedHTML
+}
+
+// cachedHTML is a MemoryStore entry
+type cachedHTML struct {
+	html    template.HTML
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]cachedHTML{}}
+}
+
+// Get returns the fragment of key unless it expired
+func (s *MemoryStore) Get(key string) (template.HTML, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached, ok := s.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !timeNow().Before(cached.expires) {
+		delete(s.entries, key)
+		return "", false, nil
+	}
+
+	return cached.html, true, nil
+}
+
+// Set stores the fragment of key for ttl
+func (s *MemoryStore) Set(key string, html template.HTML, ttl time.Duration) error {
+	s.mu.Lock()
+	s.entries[key] = cachedHTML{html: html, expires: timeNow().Add(ttl)}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Delete removes the fragment of key
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Clear removes all fragments
+func (s *MemoryStore) Clear() {
+	s.mu.Lock()
+	s.entries = map[string]cachedHTML{}
+	s.mu.Unlock()
+}
+
+// RedisClient is the part of a Redis client RedisStore needs, e.g. a small adapter of go-redis:
+//
+//	func (c adapter) Get(ctx context.Context, key string) (string, error) {
+//		s, err := c.rdb.Get(ctx, key).Result()
+//		if err == redis.Nil {
+//			return "", wutrender.ErrCacheMiss
+//		}
+//		return s, err
+//	}
+type RedisClient interface {
+	// Get returns the value of key or ErrCacheMiss
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+}
+
+// RedisStore is a CacheStore shared by all instances of an application
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore which keeps fragments under prefix+key, e.g. "fragments:"
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Get returns the fragment of key
+func (s *RedisStore) Get(key string) (template.HTML, bool, error) {
+	html, err := s.client.Get(context.Background(), s.prefix+key)
+	if errors.Is(err, ErrCacheMiss) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return template.HTML(html), true, nil
+}
+
+// Set stores the fragment of key for ttl
+func (s *RedisStore) Set(key string, html template.HTML, ttl time.Duration) error {
+	return s.client.Set(context.Background(), s.prefix+key, string(html), ttl)
+}
+
+// Delete removes the fragment of key
+func (s *RedisStore) Delete(key string) error {
+	return s.client.Del(context.Background(), s.prefix+key)
+}
+
+// cacheStore returns Options.CacheStore or the MemoryStore of the renderer
+func (r *Renderer) cacheStore() CacheStore {
+	if r.options.CacheStore != nil {
+		return r.options.CacheStore
+	}
+
+	return r.memoryStore
+}
+
+// InvalidateCache removes fragments of keys cached by cachedPartial, e.g. after the menu was edited
+func (r *Renderer) InvalidateCache(keys ...string) error {
+	store := r.cacheStore()
+
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package wutrender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a RedisClient keeping values in a map, ttl is ignored
+type fakeRedis struct {
+	values map[string]string
+	err    error
+}
+
+func (c *fakeRedis) Get(ctx context.Context, key string) (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+
+	v, ok := c.values[key]
+	if !ok {
+		return "", ErrCacheMiss
+	}
+
+	return v, nil
+}
+
+func (c *fakeRedis) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.values[key] = value
+	return nil
+}
+
+func (c *fakeRedis) Del(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(c.values, key)
+	}
+	return nil
+}
+
+func Test_MemoryStore(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	s := NewMemoryStore()
+	s.Set("nav", "<nav>", time.Minute)
+
+	html, ok, err := s.Get("nav")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, html, template.HTML("<nav>"))
+
+	now = now.Add(time.Minute)
+	_, ok, _ = s.Get("nav")
+	assert.False(t, ok)
+
+	s.Set("nav", "<nav>", time.Minute)
+	s.Delete("nav")
+	_, ok, _ = s.Get("nav")
+	assert.False(t, ok)
+
+	s.Set("footer", "<footer>", time.Minute)
+	s.Clear()
+	_, ok, _ = s.Get("footer")
+	assert.False(t, ok)
+}
+
+func Test_CacheStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ cachedPartial "nav" "5m" "nav" "user" . }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_nav.html.tmpl"), []byte(`<nav>{{ .user }}</nav>`), 0644)
+
+	client := &fakeRedis{values: map[string]string{}}
+	warnings := new(bytes.Buffer)
+	prod := false
+	r := New(Options{
+		Directory:  dir,
+		DevMode:    &prod,
+		CacheStore: NewRedisStore(client, "fragments:"),
+		Logger:     log.New(warnings, "", 0),
+	})
+	counter := new(renderCounter)
+
+	html, err := r.Copy().HTML("page", counter)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<nav>lazy</nav>")
+	assert.Equal(t, client.values["fragments:nav"], "<nav>lazy</nav>")
+
+	html, _ = r.Copy().HTML("page", counter)
+	assert.Equal(t, html.String(), "<nav>lazy</nav>")
+	assert.Equal(t, int(*counter), 1)
+
+	assert.Nil(t, r.InvalidateCache("nav", "unknown"))
+	assert.Equal(t, len(client.values), 0)
+
+	r.Copy().HTML("page", counter)
+	assert.Equal(t, int(*counter), 2)
+
+	// store errors are logged, the partial is still rendered
+	client.err = errors.New("connection refused")
+	html, err = r.Copy().HTML("page", counter)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<nav>lazy</nav>")
+	assert.Equal(t, int(*counter), 3)
+	assert.Contains(t, warnings.String(), `cachedPartial "nav": connection refused`)
+}
@@ -0,0 +1,82 @@
+// Command wutrender-gen embeds a templates directory into a Go file at build time.
+// The templates are parsed first, so template errors fail the build instead of the first request:
+//
+//	//go:generate go run github.com/8protons/wutrender/cmd/wutrender-gen -dir templates -pkg views -out templates_gen.go
+//
+// The generated NewPrecompiled(opt ...wutrender.Options) constructor doesn't touch the filesystem,
+// it still parses the embedded templates once on startup.
+// Helpers of Options.Funcs aren't known at build time, list their names with -funcs so the templates parse.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/8protons/wutrender"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "wutrender-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the flags, checks the templates and writes the Go file
+func run(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("wutrender-gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	dirs := flags.String("dir", "templates", "template directories separated by commas, later ones override earlier ones")
+	exts := flags.String("ext", ".tmpl", "template file extensions separated by commas")
+	pkg := flags.String("pkg", "", "package of the generated file (required)")
+	out := flags.String("out", "templates_gen.go", "generated file")
+	funcs := flags.String("funcs", "", "names of application helpers separated by commas, stubbed for parsing")
+	textFormats := flags.String("text-formats", "", "extra formats parsed with text/template, see Options.TextFormats")
+	defaultFormat := flags.String("default-format", "", "format of files without one, see Options.DefaultSourceFormat")
+	builtins := flags.Bool("builtins", false, "templates use the funcs package helpers, see Options.EnableBuiltins")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *pkg == "" {
+		flags.Usage()
+		return fmt.Errorf("-pkg is required")
+	}
+
+	stubs := template.FuncMap{}
+	for _, name := range split(*funcs) {
+		stubs[name] = func(args ...interface{}) (string, error) {
+			return "", nil
+		}
+	}
+
+	r, err := wutrender.NewE(wutrender.Options{
+		Directories:         split(*dirs),
+		Extensions:          split(*exts),
+		Funcs:               []template.FuncMap{stubs},
+		TextFormats:         split(*textFormats),
+		DefaultSourceFormat: *defaultFormat,
+		EnableBuiltins:      *builtins,
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.GenerateGo(*pkg, *out)
+}
+
+// split splits a comma separated flag, "" is nil
+func split(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Run(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	templates := filepath.Join(dir, "templates")
+	os.Mkdir(templates, 0755)
+	ioutil.WriteFile(filepath.Join(templates, "page.html.tmpl"), []byte(`<p>{{ price . }}</p>`), 0644)
+	out := filepath.Join(dir, "templates_gen.go")
+	stderr := new(bytes.Buffer)
+
+	err := run([]string{"-dir", templates, "-pkg", "views", "-out", out}, stderr)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `function "price" not defined`)
+
+	err = run([]string{"-dir", templates, "-pkg", "views", "-out", out, "-funcs", "price"}, stderr)
+	assert.Nil(t, err)
+
+	src, _ := ioutil.ReadFile(out)
+	assert.Contains(t, string(src), "package views")
+	assert.Contains(t, string(src), `{Name: "page.html", Text: "<p>{{ price . }}</p>"}`)
+
+	err = run([]string{"-dir", templates}, stderr)
+	assert.NotNil(t, err)
+	assert.Contains(t, stderr.String(), "-pkg")
+}
@@ -0,0 +1,94 @@
+package wutrender
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// Markers of contentFor blocks in rendered content, they never reach the output
+const (
+	contentForStart = "\x00wutrender:contentFor:"
+	contentForEnd   = "\x00wutrender:endContentFor\x00"
+)
+
+// contentFor starts a block of content for {{ yield name }} of the layout, ended by endContentFor:
+//
+//	{{ contentFor "head" }}<script src="/editor.js"></script>{{ endContentFor }}
+//
+// Blocks of the same name are joined in render order, blocks of content rendered without layout are dropped.
+func contentFor(name string) (template.HTML, error) {
+	if name == "" || strings.Contains(name, "\x00") {
+		return "", fmt.Errorf("wutrender: invalid contentFor name %q", name)
+	}
+
+	return template.HTML(contentForStart + name + "\x00"), nil
+}
+
+// endContentFor ends the block started by contentFor
+func endContentFor() template.HTML {
+	return contentForEnd
+}
+
+// splitContent cuts contentFor blocks out of rendered content and returns them by name
+func splitContent(content []byte) ([]byte, map[string][]byte, error) {
+	if !bytes.Contains(content, []byte(contentForStart)) && !bytes.Contains(content, []byte(contentForEnd)) {
+		return content, nil, nil
+	}
+
+	main := new(bytes.Buffer)
+	sections := map[string][]byte{}
+
+	for {
+		start := bytes.Index(content, []byte(contentForStart))
+		end := bytes.Index(content, []byte(contentForEnd))
+
+		if start == -1 {
+			if end != -1 {
+				return nil, nil, fmt.Errorf("wutrender: endContentFor without contentFor")
+			}
+			main.Write(content)
+			return main.Bytes(), sections, nil
+		}
+
+		if end != -1 && end < start {
+			return nil, nil, fmt.Errorf("wutrender: endContentFor without contentFor")
+		}
+
+		main.Write(content[:start])
+		content = content[start+len(contentForStart):]
+
+		i := bytes.IndexByte(content, 0)
+		name := string(content[:i])
+		content = content[i+1:]
+
+		end = bytes.Index(content, []byte(contentForEnd))
+		if end == -1 {
+			return nil, nil, fmt.Errorf("wutrender: contentFor %q without endContentFor", name)
+		}
+
+		block := content[:end]
+		if bytes.Contains(block, []byte(contentForStart)) {
+			return nil, nil, fmt.Errorf("wutrender: contentFor %q is nested in another contentFor", name)
+		}
+
+		sections[name] = append(sections[name], block...)
+		content = content[end+len(contentForEnd):]
+	}
+}
+
+// contentWithoutBlocks drops contentFor blocks of content rendered without layout
+func contentWithoutBlocks(content *bytes.Buffer) (*bytes.Buffer, error) {
+	main, sections, err := splitContent(content.Bytes())
+	if sections == nil && err == nil {
+		return content, nil
+	}
+	ReleaseBuffer(content)
+
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	return bytes.NewBuffer(main), nil
+}
@@ -0,0 +1,60 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ContentFor(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<head>{{ yield "head" }}</head><body>{{ yield }}</body>{{ yield "scripts" }}{{ yield "sidebar" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ contentFor "head" }}<meta name="author" content="{{ .Name }}">{{ endContentFor }}<p>{{ .Name }}</p>{{ partial "widget" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_widget.html.tmpl"), []byte(`<div>w</div>{{ contentFor "scripts" }}<script src="/w.js"></script>{{ endContentFor }}{{ contentFor "head" }}<link rel="stylesheet" href="/w.css">{{ endContentFor }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "open.html.tmpl"), []byte(`{{ contentFor "head" }}<meta>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "nested.html.tmpl"), []byte(`{{ contentFor "head" }}{{ contentFor "scripts" }}{{ endContentFor }}{{ endContentFor }}`), 0644)
+
+	expected := `<head><meta name="author" content="&lt;bob&gt;"><link rel="stylesheet" href="/w.css"></head>` +
+		`<body><p>&lt;bob&gt;</p><div>w</div></body><script src="/w.js"></script>`
+	binding := map[string]string{"Name": "<bob>"}
+
+	r := New(Options{Directory: dir, Layout: "layout"})
+	html, err := r.Copy().HTML("page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), expected)
+
+	// content first
+	r = New(Options{
+		Directory:  dir,
+		Layout:     "layout",
+		SecondPass: func(content []byte, binding interface{}) interface{} { return binding },
+	})
+	html, err = r.Copy().HTML("page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), expected)
+
+	rw := httptest.NewRecorder()
+	assert.Nil(t, New(Options{Directory: dir, Layout: "layout"}).Copy().WriteHTMLStream(rw, 200, "page", binding))
+	assert.Equal(t, rw.Body.String(), expected)
+
+	// blocks are dropped without layout
+	html, err = r.Copy().SetLayout("").HTML("page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<p>&lt;bob&gt;</p><div>w</div>")
+
+	_, err = r.Copy().HTML("open", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `contentFor "head" without endContentFor`)
+
+	_, err = r.Copy().HTML("nested", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "nested")
+
+	html, err = r.Copy().RenderLayout("layout", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<head></head><body></body>")
+}
@@ -2,15 +2,33 @@ package wutrender
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http"
 )
 
 var DefaultRenderer *Renderer
 
+// ErrNotInitialized is returned by the error-returning wrappers (HTMLE, JSE, ...)
+// when DefaultRenderer is nil
+var ErrNotInitialized = errors.New("wutrender: DefaultRenderer is not initialized, call wutrender.Init(opts ...Options) first")
+
 func Init(opts ...Options) {
 	DefaultRenderer = New(opts...)
 }
 
+// InitErr is Init which returns compile errors instead of panicking
+func InitErr(opts ...Options) error {
+	r, err := newRenderer(opts...)
+	if err != nil {
+		return err
+	}
+
+	DefaultRenderer = r
+
+	return nil
+}
+
 func Copy() *TemplateCopy {
 	if DefaultRenderer == nil {
 		panic("You should call wutrender.Init(opts ...Options) first")
@@ -19,6 +37,15 @@ func Copy() *TemplateCopy {
 	return DefaultRenderer.Copy()
 }
 
+// CopyE is Copy which returns ErrNotInitialized instead of panicking
+func CopyE() (*TemplateCopy, error) {
+	if DefaultRenderer == nil {
+		return nil, ErrNotInitialized
+	}
+
+	return DefaultRenderer.copy()
+}
+
 func HTML(name string, binding interface{}) (*bytes.Buffer, error) {
 	if DefaultRenderer == nil {
 		panic("You should call wutrender.Init(opts ...Options) first")
@@ -27,6 +54,16 @@ func HTML(name string, binding interface{}) (*bytes.Buffer, error) {
 	return DefaultRenderer.Copy().HTML(name, binding)
 }
 
+// HTMLE is HTML which returns ErrNotInitialized instead of panicking
+func HTMLE(name string, binding interface{}) (*bytes.Buffer, error) {
+	tmpl, err := CopyE()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.HTML(name, binding)
+}
+
 func WriteHTML(rw http.ResponseWriter, status int, name string, binding interface{}) {
 	if DefaultRenderer == nil {
 		panic("You should call wutrender.Init(opts ...Options) first")
@@ -35,6 +72,130 @@ func WriteHTML(rw http.ResponseWriter, status int, name string, binding interfac
 	DefaultRenderer.Copy().WriteHTML(rw, status, name, binding)
 }
 
+// WriteHTMLE is WriteHTML which returns ErrNotInitialized instead of panicking
+func WriteHTMLE(rw http.ResponseWriter, status int, name string, binding interface{}) error {
+	tmpl, err := CopyE()
+	if err != nil {
+		return err
+	}
+
+	tmpl.WriteHTML(rw, status, name, binding)
+
+	return nil
+}
+
+func WriteHTMLType(rw http.ResponseWriter, status int, name string, binding interface{}, contentType string) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLType(rw, status, name, binding, contentType)
+}
+
+func Fragment(name string, binding interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().Fragment(name, binding)
+}
+
+func WriteFragment(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteFragment(rw, status, name, binding)
+}
+
+func HTMLWithLayout(layout, name string, binding interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().HTMLWithLayout(layout, name, binding)
+}
+
+func WriteHTMLWithLayout(rw http.ResponseWriter, status int, layout, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLWithLayout(rw, status, layout, name, binding)
+}
+
+func WriteHTMLAuto(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLAuto(rw, r, status, name, binding)
+}
+
+func WriteHTMLSafe(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLSafe(rw, status, name, binding)
+}
+
+func WriteHTMLStream(rw http.ResponseWriter, status int, name string, binding interface{}) error {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().WriteHTMLStream(rw, status, name, binding)
+}
+
+func WriteHTMLGzip(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLGzip(rw, r, status, name, binding)
+}
+
+func HTMLContext(ctx context.Context, name string, binding interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().HTMLContext(ctx, name, binding)
+}
+
+func WriteHTMLContext(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLContext(rw, r, status, name, binding)
+}
+
+func WriteHTMLCached(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteHTMLCached(rw, r, status, name, binding)
+}
+
+func WriteError(rw http.ResponseWriter, r *http.Request, status int, err error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteError(rw, r, status, err)
+}
+
+func WriteSSE(rw http.ResponseWriter, r *http.Request, name string, bindings <-chan interface{}) error {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().WriteSSE(rw, r, name, bindings)
+}
+
 func JS(name string, binding interface{}) (*bytes.Buffer, error) {
 	if DefaultRenderer == nil {
 		panic("You should call wutrender.Init(opts ...Options) first")
@@ -43,6 +204,34 @@ func JS(name string, binding interface{}) (*bytes.Buffer, error) {
 	return DefaultRenderer.Copy().RenderFormat("js", name, binding)
 }
 
+// JSE is JS which returns ErrNotInitialized instead of panicking
+func JSE(name string, binding interface{}) (*bytes.Buffer, error) {
+	tmpl, err := CopyE()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.RenderFormat("js", name, binding)
+}
+
+func Text(name string, binding interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().Text(name, binding)
+}
+
+// TextE is Text which returns ErrNotInitialized instead of panicking
+func TextE(name string, binding interface{}) (*bytes.Buffer, error) {
+	tmpl, err := CopyE()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.Text(name, binding)
+}
+
 func WriteJS(rw http.ResponseWriter, status int, name string, binding interface{}) {
 	if DefaultRenderer == nil {
 		panic("You should call wutrender.Init(opts ...Options) first")
@@ -50,3 +239,165 @@ func WriteJS(rw http.ResponseWriter, status int, name string, binding interface{
 
 	DefaultRenderer.Copy().WriteJS(rw, status, name, binding)
 }
+
+// WriteJSE is WriteJS which returns ErrNotInitialized instead of panicking
+func WriteJSE(rw http.ResponseWriter, status int, name string, binding interface{}) error {
+	tmpl, err := CopyE()
+	if err != nil {
+		return err
+	}
+
+	tmpl.WriteJS(rw, status, name, binding)
+
+	return nil
+}
+
+func JSON(v interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().JSON(v)
+}
+
+// JSONE is JSON which returns ErrNotInitialized instead of panicking
+func JSONE(v interface{}) (*bytes.Buffer, error) {
+	tmpl, err := CopyE()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.JSON(v)
+}
+
+func WriteJSON(rw http.ResponseWriter, status int, v interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteJSON(rw, status, v)
+}
+
+// WriteJSONE is WriteJSON which returns ErrNotInitialized instead of panicking
+func WriteJSONE(rw http.ResponseWriter, status int, v interface{}) error {
+	tmpl, err := CopyE()
+	if err != nil {
+		return err
+	}
+
+	tmpl.WriteJSON(rw, status, v)
+
+	return nil
+}
+
+func JSONP(callback string, v interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().JSONP(callback, v)
+}
+
+func WriteJSONP(rw http.ResponseWriter, status int, callback string, v interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteJSONP(rw, status, callback, v)
+}
+
+func Negotiate(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().Negotiate(rw, r, status, name, binding)
+}
+
+// NegotiateE is Negotiate which returns ErrNotInitialized instead of panicking
+func NegotiateE(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) error {
+	tmpl, err := CopyE()
+	if err != nil {
+		return err
+	}
+
+	tmpl.Negotiate(rw, r, status, name, binding)
+
+	return nil
+}
+
+func XML(name string, binding interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().XML(name, binding)
+}
+
+// XMLE is XML which returns ErrNotInitialized instead of panicking
+func XMLE(name string, binding interface{}) (*bytes.Buffer, error) {
+	tmpl, err := CopyE()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.XML(name, binding)
+}
+
+func WriteXML(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteXML(rw, status, name, binding)
+}
+
+// WriteXMLE is WriteXML which returns ErrNotInitialized instead of panicking
+func WriteXMLE(rw http.ResponseWriter, status int, name string, binding interface{}) error {
+	tmpl, err := CopyE()
+	if err != nil {
+		return err
+	}
+
+	tmpl.WriteXML(rw, status, name, binding)
+
+	return nil
+}
+
+func XMLData(v interface{}) (*bytes.Buffer, error) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	return DefaultRenderer.Copy().XMLData(v)
+}
+
+// XMLDataE is XMLData which returns ErrNotInitialized instead of panicking
+func XMLDataE(v interface{}) (*bytes.Buffer, error) {
+	tmpl, err := CopyE()
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl.XMLData(v)
+}
+
+func WriteXMLData(rw http.ResponseWriter, status int, v interface{}) {
+	if DefaultRenderer == nil {
+		panic("You should call wutrender.Init(opts ...Options) first")
+	}
+
+	DefaultRenderer.Copy().WriteXMLData(rw, status, v)
+}
+
+// WriteXMLDataE is WriteXMLData which returns ErrNotInitialized instead of panicking
+func WriteXMLDataE(rw http.ResponseWriter, status int, v interface{}) error {
+	tmpl, err := CopyE()
+	if err != nil {
+		return err
+	}
+
+	tmpl.WriteXMLData(rw, status, v)
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_NotInitialized(t *testing.T) {
+	DefaultRenderer = nil
+
+	_, err := CopyE()
+	assert.Equal(t, err, ErrNotInitialized)
+
+	_, err = HTMLE("base/hello", nil)
+	assert.Equal(t, err, ErrNotInitialized)
+
+	_, err = JSE("base/hello", nil)
+	assert.Equal(t, err, ErrNotInitialized)
+
+	_, err = JSONE(nil)
+	assert.Equal(t, err, ErrNotInitialized)
+
+	rw := httptest.NewRecorder()
+	assert.Equal(t, WriteHTMLE(rw, 200, "base/hello", nil), ErrNotInitialized)
+	assert.Equal(t, rw.Body.Len(), 0)
+
+	assert.Panics(t, func() { HTML("base/hello", nil) })
+}
+
+func Test_InitErr(t *testing.T) {
+	DefaultRenderer = nil
+	defer func() { DefaultRenderer = nil }()
+
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "broken.html.tmpl"), []byte("{{ if }}"), 0644)
+
+	err := InitErr(Options{Directory: dir})
+	assert.NotNil(t, err)
+	assert.Nil(t, DefaultRenderer)
+
+	err = InitErr(Options{Directory: "fixtures"})
+	assert.Nil(t, err)
+
+	html, err := HTMLE("base/hello", "world")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<div>Hello world</div>")
+}
@@ -0,0 +1,202 @@
+package wutrender
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lines shown before and after the failing line of the development error page
+const excerptContext = 5
+
+// renderError keeps the template name and binding of a failed render in development for the error page
+type renderError struct {
+	name    string
+	binding interface{}
+	err     error
+}
+
+func (e *renderError) Error() string {
+	return e.err.Error()
+}
+
+func (e *renderError) Unwrap() error {
+	return e.err
+}
+
+// devError wraps err of rendering fullName in development
+func (tmpl *TemplateCopy) devError(fullName string, binding interface{}, err error) error {
+	if err == nil || !tmpl.renderer.isDev() {
+		return err
+	}
+
+	var wrapped *renderError
+	if errors.As(err, &wrapped) {
+		return err
+	}
+
+	return &renderError{name: fullName, binding: binding, err: err}
+}
+
+// Location of "template: users/show.html:3:12: executing ..." errors
+var errorLocation = regexp.MustCompile(`template: ([^:\s]+):(\d+):`)
+
+// devErrorPage is the binding of devErrorTemplate
+type devErrorPage struct {
+	// Rendered template
+	Name string
+	// Template and line which failed, Line is 0 if unknown
+	File    string
+	Line    int
+	Excerpt []excerptLine
+	// Map keys or struct fields of the binding
+	Keys []string
+	// Messages of the error and the errors it wraps
+	Chain []string
+}
+
+type excerptLine struct {
+	Number  int
+	Text    string
+	Failing bool
+}
+
+// writeDevError responds with the development error page of err
+func (tmpl *TemplateCopy) writeDevError(rw http.ResponseWriter, err error) {
+	page := devErrorPage{}
+
+	var failed *renderError
+	if errors.As(err, &failed) {
+		page.Name = failed.name
+		page.Keys = bindingKeys(failed.binding)
+	}
+
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if _, ok := e.(*renderError); !ok {
+			page.Chain = append(page.Chain, e.Error())
+		}
+	}
+
+	if m := errorLocation.FindStringSubmatch(err.Error()); m != nil {
+		page.File = m[1]
+		page.Line, _ = strconv.Atoi(m[2])
+		page.Excerpt = tmpl.renderer.excerpt(page.File, page.Line)
+	}
+
+	buf := getBuffer()
+	if execErr := devErrorTemplate.Execute(buf, page); execErr != nil {
+		ReleaseBuffer(buf)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl.writeBody(rw, http.StatusInternalServerError, ContentHTML, buf)
+}
+
+// excerpt returns the source lines around line of template name, nil if its source can't be read
+func (r *Renderer) excerpt(name string, line int) []excerptLine {
+	sources, err := r.loadSources()
+	if err != nil {
+		return nil
+	}
+
+	for _, src := range sources {
+		if src.Name != name {
+			continue
+		}
+
+		lines := strings.Split(src.Text, "\n")
+		if line < 1 || line > len(lines) {
+			return nil
+		}
+
+		var excerpt []excerptLine
+		for n := line - excerptContext; n <= line+excerptContext; n++ {
+			if n >= 1 && n <= len(lines) {
+				excerpt = append(excerpt, excerptLine{Number: n, Text: lines[n-1], Failing: n == line})
+			}
+		}
+
+		return excerpt
+	}
+
+	return nil
+}
+
+// bindingKeys returns the sorted string keys of a map or the exported fields of a struct
+func bindingKeys(binding interface{}) []string {
+	v := reflect.ValueOf(binding)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var keys []string
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.IsExported() {
+				keys = append(keys, field.Name)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// devErrorTemplate is the internal development error page
+var devErrorTemplate = template.Must(template.New("wutrender/error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Render error{{ with .Name }}: {{ . }}{{ end }}</title>
+<style>
+body { font: 14px/1.5 sans-serif; margin: 2em; color: #222; }
+h1 { color: #b00; font-size: 1.4em; }
+pre, code { font: 13px/1.5 monospace; }
+.excerpt { background: #f6f6f6; padding: .5em 0; }
+.excerpt div { padding: 0 1em; white-space: pre; }
+.excerpt .failing { background: #fdd; }
+.excerpt span { display: inline-block; width: 3em; color: #888; }
+</style>
+</head>
+<body>
+<h1>Render error{{ with .Name }} in {{ . }}{{ end }}</h1>
+{{- if .Excerpt }}
+<h2>{{ .File }}:{{ .Line }}</h2>
+<pre class="excerpt">
+{{- range .Excerpt }}<div{{ if .Failing }} class="failing"{{ end }}><span>{{ .Number }}</span>{{ .Text }}</div>{{ end -}}
+</pre>
+{{- end }}
+<h2>Errors</h2>
+<ol>
+{{- range .Chain }}
+<li><code>{{ . }}</code></li>
+{{- end }}
+</ol>
+{{- if .Keys }}
+<h2>Binding</h2>
+<ul>
+{{- range .Keys }}
+<li><code>{{ . }}</code></li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`))
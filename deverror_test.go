@@ -0,0 +1,55 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_DevErrorPage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "users"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "users", "show.html.tmpl"), []byte("<h1>{{ .Name }}</h1>\n<p>\n{{ index .Tags 5 }}\n</p>"), 0644)
+
+	dev, prod := true, false
+	binding := map[string]interface{}{"Name": "<bob>", "Tags": []string{}}
+
+	r := New(Options{Directory: dir, DevMode: &dev})
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "users/show", binding)
+
+	body := rw.Body.String()
+	assert.Equal(t, rw.Code, 500)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	assert.Contains(t, body, "<h1>Render error in users/show.html</h1>")
+	assert.Contains(t, body, "<h2>users/show.html:3</h2>")
+	assert.Contains(t, body, `<div><span>2</span>&lt;p&gt;</div><div class="failing"><span>3</span>{{ index .Tags 5 }}</div>`)
+	assert.Contains(t, body, "<li><code>Name</code></li>\n<li><code>Tags</code></li>")
+	assert.Contains(t, body, "index out of range: 5")
+
+	// production keeps the error text
+	r = New(Options{Directory: dir, DevMode: &prod})
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "users/show", binding)
+
+	assert.Equal(t, rw.Code, 500)
+	assert.NotContains(t, rw.Body.String(), "Render error")
+}
+
+func Test_BindingKeys(t *testing.T) {
+	type user struct {
+		Name  string
+		Email string
+		token string
+	}
+
+	assert.Equal(t, bindingKeys(map[string]int{"b": 1, "a": 2}), []string{"a", "b"})
+	assert.Equal(t, bindingKeys(&user{}), []string{"Email", "Name"})
+	assert.Equal(t, bindingKeys(map[int]int{1: 1}), []string(nil))
+	assert.Equal(t, bindingKeys("text"), []string(nil))
+	assert.Equal(t, bindingKeys(nil), []string(nil))
+}
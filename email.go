@@ -0,0 +1,90 @@
+package wutrender
+
+import (
+	"bytes"
+	"golang.org/x/net/html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Email renders "name.html" and "name.txt" (text/template, no escaping) parts of an email.
+// Both parts are localized and themed like RenderFormat. Without "name.txt" textBody is nil,
+// or derived from the html part with Options.AutoTextFromHTML.
+func (tmpl *TemplateCopy) Email(name string, binding interface{}) (htmlBody, textBody *bytes.Buffer, err error) {
+	htmlBody, err = tmpl.RenderFormat("html", name, binding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tmpl.renderable("txt", name) {
+		textBody, err = tmpl.RenderFormat("txt", name, binding)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if tmpl.options.AutoTextFromHTML {
+		textBody = bytes.NewBufferString(htmlToText(htmlBody.String()))
+	}
+
+	return htmlBody, textBody, nil
+}
+
+var (
+	spaces   = regexp.MustCompile(`[ \t\r\f]+`)
+	newlines = regexp.MustCompile(`\n\s*\n\s*\n+`)
+)
+
+// Tags which end a line in the plain text
+var blockTags = map[string]bool{
+	"br": true, "p": true, "div": true, "li": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// htmlToText strips tags, skips <script>/<style>/<head> and unescapes entities
+func htmlToText(s string) string {
+	var b bytes.Buffer
+	skip := 0
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken && z.Err() == io.EOF {
+			break
+		}
+
+		name, _ := z.TagName()
+		tag := string(name)
+
+		switch tt {
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(strings.Replace(string(z.Text()), "\n", " ", -1))
+			}
+		case html.StartTagToken:
+			if tag == "script" || tag == "style" || tag == "head" {
+				skip++
+			} else if tag == "br" {
+				b.WriteString("\n")
+			}
+		case html.SelfClosingTagToken:
+			if tag == "br" {
+				b.WriteString("\n")
+			}
+		case html.EndTagToken:
+			if tag == "script" || tag == "style" || tag == "head" {
+				if skip > 0 {
+					skip--
+				}
+			} else if blockTags[tag] {
+				b.WriteString("\n\n")
+			}
+		}
+	}
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+	}
+
+	return strings.TrimSpace(newlines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n"
+}
@@ -0,0 +1,71 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Email(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	binding := map[string]string{"Name": "Tom & Jerry"}
+
+	htmlBody, textBody, err := r.Copy().Email("email/welcome", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, htmlBody.String(), "<p>Hi Tom &amp; Jerry, welcome!</p>\n<p>Thanks</p>")
+	assert.Equal(t, textBody.String(), "Hi Tom & Jerry, welcome!\n\nThanks\n")
+
+	htmlBody, textBody, err = r.Copy().Email("email/receipt", binding)
+	assert.Nil(t, err)
+	assert.Contains(t, htmlBody.String(), "<h1>Receipt for Tom &amp; Jerry</h1>")
+	assert.Nil(t, textBody)
+
+	_, _, err = r.Copy().Email("email/missing", binding)
+	assert.NotNil(t, err)
+}
+
+func Test_EmailAutoText(t *testing.T) {
+	r := New(Options{
+		Directory:        "fixtures",
+		AutoTextFromHTML: true,
+	})
+
+	_, textBody, err := r.Copy().Email("email/receipt", map[string]string{"Name": "Tom & Jerry"})
+	assert.Nil(t, err)
+	assert.Equal(t, textBody.String(), "Receipt for Tom & Jerry\n\nTotal: $10 & no fees\n\nLine\nbreak\n")
+}
+
+func Test_EmailLocalized(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "dark", "email"), 0755)
+	os.Mkdir(filepath.Join(dir, "email"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "email", "w.html.tmpl"), []byte(`<p>Welcome</p>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "email", "w.html.de.tmpl"), []byte(`<p>Willkommen</p>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "email", "w.txt.de.tmpl"), []byte(`Willkommen`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "dark", "email", "w.txt.tmpl"), []byte(`Welcome, dark`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Locales:   []string{"en", "de"},
+		Locale:    "de-DE",
+	})
+
+	htmlBody, textBody, err := r.Copy().Email("email/w", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, htmlBody.String(), "<p>Willkommen</p>")
+	assert.Equal(t, textBody.String(), "Willkommen")
+
+	_, textBody, err = r.Copy().SetLocale("en").SetTheme("dark").Email("email/w", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, textBody.String(), "Welcome, dark")
+
+	_, textBody, err = r.Copy().SetLocale("en").Email("email/w", nil)
+	assert.Nil(t, err)
+	assert.Nil(t, textBody)
+}
@@ -0,0 +1,211 @@
+package wutrender
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Engine compiles templates of another template language (pongo2, jet, ...), see Options.Engines.
+// An Engine may be shared by renderers (CopyFrom, BaseRenderer), so every Compile returns a new template set
+// which belongs to the renderer.
+type Engine interface {
+	// Compile parses sources named like other templates ("users/show.html") into a new template set
+	Compile(sources []Source) (EngineTemplates, error)
+}
+
+// EngineTemplates is a template set compiled by an Engine. It's used by renders running concurrently,
+// implementations must be safe for concurrent use.
+type EngineTemplates interface {
+	// Lookup reports whether the set has template name
+	Lookup(name string) bool
+	// Execute renders template name with binding into w. vars are extra template variables,
+	// layouts get the rendered content as "body" (template.HTML).
+	Execute(w io.Writer, name string, binding interface{}, vars map[string]interface{}) error
+}
+
+// engineSet is the template sets of Options.Engines compiled for one renderer,
+// templates of the BaseRenderer sets are used unless the renderer has its own
+type engineSet struct {
+	// Sorted extensions of the sets
+	exts      []string
+	templates map[string]EngineTemplates
+	names     []string
+	base      *engineSet
+}
+
+// lookup returns the set which has template fullName, nil if it's not an engine template
+func (s *engineSet) lookup(fullName string) EngineTemplates {
+	if s == nil {
+		return nil
+	}
+
+	for _, ext := range s.exts {
+		if templates := s.templates[ext]; templates.Lookup(fullName) {
+			return templates
+		}
+	}
+
+	return s.base.lookup(fullName)
+}
+
+// allNames returns full names of the templates of the sets and of the base sets
+func (s *engineSet) allNames() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := append([]string(nil), s.names...)
+	for _, name := range s.base.allNames() {
+		if !containsName(s.names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// containsName reports whether names has name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceEngine returns the engine of src by file extension, nil for html/template and text/template sources
+func (r *Renderer) sourceEngine(src Source) Engine {
+	if len(r.options.Engines) == 0 || src.path == "" {
+		return nil
+	}
+
+	return r.options.Engines[filepath.Ext(src.path)]
+}
+
+// compileEngines compiles the sources of every engine into new sets of the renderer
+func (r *Renderer) compileEngines(sources []Source) (*engineSet, error) {
+	var base *engineSet
+	if r.options.BaseRenderer != nil {
+		base = r.options.BaseRenderer.engines()
+	}
+
+	if len(r.options.Engines) == 0 && base == nil {
+		return nil, nil
+	}
+
+	bySource := map[string][]Source{}
+	set := &engineSet{templates: map[string]EngineTemplates{}, base: base}
+	for _, src := range sources {
+		if r.sourceEngine(src) != nil {
+			ext := filepath.Ext(src.path)
+			bySource[ext] = append(bySource[ext], src)
+			set.names = append(set.names, src.Name)
+		}
+	}
+
+	for _, ext := range r.engineExtensions() {
+		templates, err := r.options.Engines[ext].Compile(bySource[ext])
+		if err != nil {
+			return nil, err
+		}
+
+		set.exts = append(set.exts, ext)
+		set.templates[ext] = templates
+	}
+
+	return set, nil
+}
+
+// engines returns the current engine sets
+func (r *Renderer) engines() *engineSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.engineSet
+}
+
+// engineExtensions returns the sorted extensions of Options.Engines
+func (r *Renderer) engineExtensions() []string {
+	exts := make([]string, 0, len(r.options.Engines))
+	for ext := range r.options.Engines {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	return exts
+}
+
+// engineOf returns the engine set of the copy which has template fullName, nil if it's not an engine template
+func (tmpl *TemplateCopy) engineOf(fullName string) EngineTemplates {
+	return tmpl.engines.lookup(fullName)
+}
+
+// executeEngine renders fullName with engine, html with the layout of the copy and other formats with Options.Layouts.
+// The html layout may be a template of any engine or of html/template.
+func (tmpl *TemplateCopy) executeEngine(engine EngineTemplates, format, fullName string, binding interface{}) (*bytes.Buffer, error) {
+	buf := getBuffer()
+	if err := engine.Execute(tmpl.writer(buf), fullName, binding, nil); err != nil {
+		ReleaseBuffer(buf)
+		return new(bytes.Buffer), err
+	}
+
+	if layout := tmpl.options.layoutOf(format); format != "html" && layout != "" {
+		return tmpl.executeFormatLayout(format, layout+"."+format, fullName, binding, func() (*bytes.Buffer, error) {
+			return buf, nil
+		})
+	}
+	if format != "html" || tmpl.layout == "" {
+		return buf, nil
+	}
+
+	if layoutEngine := tmpl.engineOf(tmpl.layout + ".html"); layoutEngine != nil {
+		return tmpl.executeEngineLayout(layoutEngine, buf, binding)
+	}
+
+	if err := tmpl.layoutExists(); err != nil {
+		ReleaseBuffer(buf)
+		return new(bytes.Buffer), err
+	}
+
+	content := template.HTML(buf.String())
+	ReleaseBuffer(buf)
+
+	layout := tmpl.layout + ".html"
+	tmpl.renderer.markUsed(layout)
+	tmpl.withLayout = true
+
+	yielded := false
+	tmpl.yield = yieldFunc(func() (template.HTML, map[string][]byte, error) {
+		return content, nil, nil
+	}, &yielded)
+
+	layout, err := tmpl.extendLayouts(layout, fullName, binding)
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	return tmpl.executeTemplate(layout, binding)
+}
+
+// executeEngineLayout renders the layout of the copy, a template of engine, around the rendered content
+func (tmpl *TemplateCopy) executeEngineLayout(engine EngineTemplates, content *bytes.Buffer, binding interface{}) (*bytes.Buffer, error) {
+	layout := tmpl.layout + ".html"
+	tmpl.renderer.markUsed(layout)
+	tmpl.withLayout = true
+
+	vars := map[string]interface{}{"body": template.HTML(content.String())}
+	ReleaseBuffer(content)
+
+	buf := getBuffer()
+	if err := engine.Execute(tmpl.writer(buf), layout, binding, vars); err != nil {
+		ReleaseBuffer(buf)
+		return new(bytes.Buffer), err
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,89 @@
+// Package jet renders wutrender templates with Jet:
+//
+//	wutrender.New(wutrender.Options{
+//		Layout:  "layout",
+//		Engines: map[string]wutrender.Engine{".jet": jet.New()},
+//	})
+//
+// "users/show.html.jet" is rendered as "users/show" html like any other template. Templates include, import
+// and extend each other by template name: {{ include "/users/row.html" }}. The binding is the template
+// context ({{ .Name }}). Layouts render the content with {{ body }}.
+package jet
+
+import (
+	"fmt"
+	"github.com/8protons/wutrender"
+	"github.com/CloudyKit/jet/v6"
+	"html/template"
+	"io"
+)
+
+// Engine is wutrender.Engine of Jet templates
+type Engine struct {
+	options []jet.Option
+}
+
+// New creates an Engine with options of jet.NewSet (e.g. jet.WithDelims), add it to Options.Engines
+func New(options ...jet.Option) *Engine {
+	return &Engine{options: options}
+}
+
+// Templates is a set of Jet templates compiled by Engine
+type Templates struct {
+	set   *jet.Set
+	names map[string]bool
+}
+
+// Compile parses sources into a new set
+func (e *Engine) Compile(sources []wutrender.Source) (wutrender.EngineTemplates, error) {
+	loader := jet.NewInMemLoader()
+	names := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		loader.Set(src.Name, src.Text)
+		names[src.Name] = true
+	}
+
+	set := jet.NewSet(loader, e.options...)
+	for _, src := range sources {
+		if _, err := set.GetTemplate("/" + src.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Templates{set: set, names: names}, nil
+}
+
+// Lookup reports whether template name was compiled
+func (ts *Templates) Lookup(name string) bool {
+	return ts.names[name]
+}
+
+// Execute renders template name, template.HTML vars are not escaped
+func (ts *Templates) Execute(w io.Writer, name string, binding interface{}, vars map[string]interface{}) error {
+	if !ts.names[name] {
+		return fmt.Errorf("jet: template %q not found", name)
+	}
+
+	t, err := ts.set.GetTemplate("/" + name)
+	if err != nil {
+		return err
+	}
+
+	variables := jet.VarMap{}
+	for key, v := range vars {
+		if html, ok := v.(template.HTML); ok {
+			variables.Set(key, safeHTML(html))
+		} else {
+			variables.Set(key, v)
+		}
+	}
+
+	return t.Execute(w, variables, binding)
+}
+
+// safeHTML is written without escaping
+type safeHTML string
+
+func (h safeHTML) Render(r *jet.Runtime) {
+	io.WriteString(r.Writer, string(h))
+}
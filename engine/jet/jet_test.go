@@ -0,0 +1,56 @@
+package jet
+
+import (
+	"bytes"
+	"github.com/8protons/wutrender"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Engine(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "users"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "users", "show.html.jet"), []byte(`<h1>{{ .Name }}</h1>{{ include "/users/row.html" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "users", "row.html.jet"), []byte(`{{ range _, tag := .Tags }}<i>{{ tag }}</i>{{ end }}`), 0644)
+
+	r := wutrender.New(wutrender.Options{
+		Directory: dir,
+		Layout:    "layout",
+		Engines:   map[string]wutrender.Engine{".jet": New()},
+	})
+
+	binding := struct {
+		Name string
+		Tags []string
+	}{"<bob>", []string{"a", "b"}}
+
+	// jet content in an html/template layout
+	html, err := r.Copy().HTML("users/show", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><h1>&lt;bob&gt;</h1><i>a</i><i>b</i></main>")
+
+	_, err = r.Copy().HTML("users/missing", nil)
+	assert.NotNil(t, err)
+
+	ts, err := New().Compile([]wutrender.Source{
+		{Name: "layout.html", Text: `<main>{{ body }}</main>`},
+		{Name: "page.html", Text: `<p>{{ . }}</p>`},
+	})
+	assert.Nil(t, err)
+	assert.True(t, ts.Lookup("page.html"))
+	assert.False(t, ts.Lookup("missing.html"))
+
+	buf := new(bytes.Buffer)
+	err = ts.Execute(buf, "layout.html", nil, map[string]interface{}{"body": template.HTML("<p>safe</p>")})
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "<main><p>safe</p></main>")
+
+	_, err = New().Compile([]wutrender.Source{{Name: "broken.html", Text: "{{ if }}"}})
+	assert.NotNil(t, err)
+}
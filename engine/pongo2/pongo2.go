@@ -0,0 +1,105 @@
+// Package pongo2 renders wutrender templates with pongo2 (Django syntax):
+//
+//	wutrender.New(wutrender.Options{
+//		Layout:  "layout",
+//		Engines: map[string]wutrender.Engine{".pongo": pongo2.New()},
+//	})
+//
+// "users/show.html.pongo" is rendered as "users/show" html like any other template. Templates include
+// and extend each other by template name: {% include "users/row.html" %}. Map bindings are template variables,
+// other bindings are the binding variable. Layouts render the content with {{ body }}.
+package pongo2
+
+import (
+	"fmt"
+	"github.com/8protons/wutrender"
+	"github.com/flosch/pongo2/v6"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Engine is wutrender.Engine of pongo2 templates
+type Engine struct{}
+
+// New creates an Engine, add it to Options.Engines
+func New() *Engine {
+	return &Engine{}
+}
+
+// Templates is a set of pongo2 templates compiled by Engine
+type Templates struct {
+	templates map[string]*pongo2.Template
+}
+
+// Compile parses sources into a new set
+func (e *Engine) Compile(sources []wutrender.Source) (wutrender.EngineTemplates, error) {
+	l := loader{}
+	for _, src := range sources {
+		l[src.Name] = src.Text
+	}
+
+	set := pongo2.NewSet("wutrender", l)
+	templates := make(map[string]*pongo2.Template, len(sources))
+	for _, src := range sources {
+		t, err := set.FromFile(src.Name)
+		if err != nil {
+			return nil, err
+		}
+		templates[src.Name] = t
+	}
+
+	return &Templates{templates: templates}, nil
+}
+
+// Lookup reports whether template name was compiled
+func (ts *Templates) Lookup(name string) bool {
+	_, ok := ts.templates[name]
+
+	return ok
+}
+
+// Execute renders template name, template.HTML vars are not escaped
+func (ts *Templates) Execute(w io.Writer, name string, binding interface{}, vars map[string]interface{}) error {
+	t, ok := ts.templates[name]
+	if !ok {
+		return fmt.Errorf("pongo2: template %q not found", name)
+	}
+
+	ctx := pongo2.Context{}
+	switch b := binding.(type) {
+	case nil:
+	case pongo2.Context:
+		ctx.Update(b)
+	case map[string]interface{}:
+		ctx.Update(b)
+	default:
+		ctx["binding"] = binding
+	}
+
+	for key, v := range vars {
+		if html, ok := v.(template.HTML); ok {
+			ctx[key] = pongo2.AsSafeValue(string(html))
+		} else {
+			ctx[key] = v
+		}
+	}
+
+	return t.ExecuteWriter(ctx, w)
+}
+
+// loader resolves templates by name from the compiled sources
+type loader map[string]string
+
+func (l loader) Abs(base, name string) string {
+	return name
+}
+
+func (l loader) Get(name string) (io.Reader, error) {
+	text, ok := l[name]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", name)
+	}
+
+	return strings.NewReader(text), nil
+}
@@ -0,0 +1,46 @@
+package pongo2
+
+import (
+	"github.com/8protons/wutrender"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Engine(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "users"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.pongo"), []byte(`<main>{{ body }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "users", "show.html.pongo"), []byte(`<h1>{{ name|upper }}</h1>{% include "users/row.html" %}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "users", "row.html.pongo"), []byte(`{% for tag in tags %}<i>{{ tag }}</i>{% endfor %}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "users", "plain.html.pongo"), []byte(`{{ binding }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`<p>{{ . }}</p>`), 0644)
+
+	r := wutrender.New(wutrender.Options{
+		Directory: dir,
+		Layout:    "layout",
+		Engines:   map[string]wutrender.Engine{".pongo": New()},
+	})
+
+	html, err := r.Copy().HTML("users/show", map[string]interface{}{"name": "<bob>", "tags": []string{"a", "b"}})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><h1>&lt;BOB&gt;</h1><i>a</i><i>b</i></main>")
+
+	html, err = r.Copy().SetLayout("").HTML("users/plain", "text")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "text")
+
+	// html/template content in a pongo2 layout
+	html, err = r.Copy().HTML("page", "html")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><p>html</p></main>")
+
+	_, err = r.Copy().HTML("users/missing", nil)
+	assert.NotNil(t, err)
+
+	_, err = New().Compile([]wutrender.Source{{Name: "broken.html", Text: "{% if %}"}})
+	assert.NotNil(t, err)
+}
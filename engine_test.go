@@ -0,0 +1,137 @@
+package wutrender
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// upperEngine renders its sources uppercased, "BODY" is replaced by the body var
+type upperEngine struct{}
+
+type upperTemplates map[string]string
+
+func (e upperEngine) Compile(sources []Source) (EngineTemplates, error) {
+	ts := upperTemplates{}
+	for _, src := range sources {
+		if strings.Contains(src.Text, "{%") {
+			return nil, fmt.Errorf("upper: %s: unexpected {%%", src.Name)
+		}
+		ts[src.Name] = src.Text
+	}
+
+	return ts, nil
+}
+
+func (ts upperTemplates) Lookup(name string) bool {
+	_, ok := ts[name]
+	return ok
+}
+
+func (ts upperTemplates) Execute(w io.Writer, name string, binding interface{}, vars map[string]interface{}) error {
+	out := strings.ToUpper(ts[name])
+	if body, ok := vars["body"]; ok {
+		out = strings.Replace(out, "BODY", fmt.Sprint(body), 1)
+	}
+	if strings.Contains(out, "FAIL") {
+		io.WriteString(w, out)
+		return fmt.Errorf("upper: %s failed", name)
+	}
+	_, err := io.WriteString(w, out+fmt.Sprint(binding))
+
+	return err
+}
+
+func Test_Engines(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.up"), []byte(`<p>page `), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "wrap.html.up"), []byte(`<div>body</div>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "hello.html.tmpl"), []byte(`<p>{{ . }}</p>`), 0644)
+
+	r, err := NewE(Options{
+		Directory: dir,
+		Layout:    "layout",
+		Engines:   map[string]Engine{".up": upperEngine{}},
+	})
+	assert.Nil(t, err)
+
+	html, err := r.Copy().HTML("page", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><P>PAGE a</main>")
+
+	html, err = r.Copy().SetLayout("").HTML("page", "b")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<P>PAGE b")
+
+	// engine layouts
+	html, err = r.Copy().SetLayout("wrap").HTML("hello", "c")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<DIV><p>c</p></DIV>c")
+
+	var w strings.Builder
+	assert.Nil(t, r.Copy().RenderTo(&w, "html", "page", "d"))
+	assert.Equal(t, w.String(), "<main><P>PAGE d</main>")
+
+	w.Reset()
+	lazy, err := r.Copy().Renderer("html", "page", "e")
+	assert.Nil(t, err)
+	lazy.WriteTo(&w)
+	assert.Equal(t, w.String(), "<main><P>PAGE e</main>")
+
+	format, _, err := r.Copy().RenderPreferred([]string{"txt", "html"}, "page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, format, "html")
+
+	assert.True(t, r.Exists("page.html"))
+	assert.Equal(t, r.TemplateNames(), []string{"hello.html", "layout.html", "page.html", "wrap.html"})
+
+	// previews and tenants compile their own sets of the shared engine
+	preview, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(preview)
+	ioutil.WriteFile(filepath.Join(preview, "other.html.up"), []byte(`<p>other `), 0644)
+
+	tmpl, err := r.CopyFrom(preview)
+	assert.Nil(t, err)
+	html, err = tmpl.SetLayout("").HTML("other", "e")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<P>OTHER e")
+	html, err = tmpl.SetLayout("").HTML("page", "f")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<P>PAGE f")
+
+	html, err = r.Copy().HTML("page", "g")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><P>PAGE g</main>")
+	assert.False(t, r.Exists("other.html"))
+
+	tenant, err := NewE(Options{Directory: preview, BaseRenderer: r, Engines: r.options.Engines})
+	assert.Nil(t, err)
+	assert.Equal(t, tenant.TemplateNames(), []string{"hello.html", "layout.html", "other.html", "page.html", "wrap.html"})
+
+	html, err = r.Copy().HTML("page", "h")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><P>PAGE h</main>")
+
+	// failed renders return an empty buffer
+	ioutil.WriteFile(filepath.Join(dir, "fail.html.up"), []byte(`<p>fail`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "failwrap.html.up"), []byte(`<div>body fail</div>`), 0644)
+	assert.Nil(t, r.Reload())
+
+	html, err = r.Copy().HTML("fail", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, html.Len(), 0)
+
+	html, err = r.Copy().SetLayout("failwrap").HTML("page", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, html.Len(), 0)
+
+	ioutil.WriteFile(filepath.Join(dir, "broken.html.up"), []byte(`{% if %}`), 0644)
+	assert.NotNil(t, r.Reload())
+}
@@ -0,0 +1,87 @@
+package wutrender
+
+import (
+	"net/http"
+)
+
+// ErrorPage is the binding of error page templates
+type ErrorPage struct {
+	Status int
+	// http.StatusText of Status
+	Title string
+	// The error in development, nil in production
+	Err error
+}
+
+// ErrorPages sets html templates rendered by WriteError and by write helpers failing to render in production,
+// e.g. {404: "errors/404", 500: "errors/500"}. Page 0 is used for statuses without a page.
+func (r *Renderer) ErrorPages(pages map[int]string) {
+	copied := make(map[int]string, len(pages))
+	for status, name := range pages {
+		copied[status] = name
+	}
+
+	r.errorPagesMu.Lock()
+	r.errorPages = copied
+	r.errorPagesMu.Unlock()
+}
+
+// errorPage returns the error page template of status
+func (r *Renderer) errorPage(status int) (string, bool) {
+	r.errorPagesMu.RLock()
+	defer r.errorPagesMu.RUnlock()
+
+	if name, ok := r.errorPages[status]; ok {
+		return name, true
+	}
+	name, ok := r.errorPages[0]
+
+	return name, ok
+}
+
+// WriteError responds with the error page of status (see Renderer.ErrorPages) rendered for r (may be nil).
+// Without a page, or if it fails to render, it responds with the error text in development
+// and the status text in production.
+func (tmpl *TemplateCopy) WriteError(rw http.ResponseWriter, r *http.Request, status int, err error) {
+	if r != nil {
+		tmpl.SetRequest(r)
+	}
+
+	dev := tmpl.renderer.isDev()
+
+	if name, ok := tmpl.renderer.errorPage(status); ok {
+		page := ErrorPage{Status: status, Title: http.StatusText(status)}
+		if dev {
+			page.Err = err
+		}
+
+		html, renderErr := tmpl.errorPageCopy().HTML(name, page)
+		if renderErr == nil {
+			tmpl.write(rw, status, ContentHTML, html)
+			return
+		}
+		tmpl.renderer.warnf("error page %s: %v", name, renderErr)
+	}
+
+	text := http.StatusText(status)
+	if dev && err != nil {
+		text = err.Error()
+	}
+
+	http.Error(rw, text, status)
+}
+
+// errorPageCopy returns the copy to render error pages with, a new one with the request, theme and locale
+// of tmpl if a timed out render still holds tmpl
+func (tmpl *TemplateCopy) errorPageCopy() *TemplateCopy {
+	if !tmpl.abandoned {
+		return tmpl
+	}
+
+	page := tmpl.renderer.Copy().SetRequest(tmpl.request).SetTheme(tmpl.theme)
+	if tmpl.locale != "" {
+		page.SetLocale(tmpl.locale)
+	}
+
+	return page
+}
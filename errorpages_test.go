@@ -0,0 +1,64 @@
+package wutrender
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ErrorPages(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "errors"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "errors", "404.html.tmpl"), []byte(`<h1>{{ .Status }} {{ .Title }}</h1>{{ with .Err }}<pre>{{ . }}</pre>{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "errors", "500.html.tmpl"), []byte(`<h1>Oops</h1>{{ with .Err }}<pre>{{ . }}</pre>{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "broken.html.tmpl"), []byte(`{{ .Missing.Field }}`), 0644)
+
+	dev, prod := true, false
+	r := New(Options{Directory: dir, DevMode: &prod})
+
+	// without pages
+	rw := httptest.NewRecorder()
+	r.Copy().WriteError(rw, nil, 404, errors.New("no such user"))
+	assert.Equal(t, rw.Code, 404)
+	assert.Equal(t, rw.Body.String(), "Not Found\n")
+
+	// template errors of write helpers are hidden too
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "broken", struct{ Secret string }{"s3cr3t"})
+	assert.Equal(t, rw.Code, 500)
+	assert.Equal(t, rw.Body.String(), "Internal Server Error\n")
+
+	r.ErrorPages(map[int]string{404: "errors/404", 500: "errors/500"})
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteError(rw, nil, 404, errors.New("no such user"))
+	assert.Equal(t, rw.Code, 404)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	assert.Equal(t, rw.Body.String(), "<h1>404 Not Found</h1>")
+
+	// write helpers respond with the 500 page
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "broken", struct{}{})
+	assert.Equal(t, rw.Code, 500)
+	assert.Equal(t, rw.Body.String(), "<h1>Oops</h1>")
+
+	// statuses without a page
+	rw = httptest.NewRecorder()
+	r.Copy().WriteError(rw, nil, 403, nil)
+	assert.Equal(t, rw.Code, 403)
+	assert.Equal(t, rw.Body.String(), "Forbidden\n")
+
+	// the error is shown in development
+	r = New(Options{Directory: dir, DevMode: &dev})
+	r.ErrorPages(map[int]string{0: "errors/404"})
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteError(rw, nil, 410, errors.New("user <deleted>"))
+	assert.Equal(t, rw.Code, 410)
+	assert.Equal(t, rw.Body.String(), "<h1>410 Gone</h1><pre>user &lt;deleted&gt;</pre>")
+}
@@ -0,0 +1,58 @@
+package wutrender
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ErrTemplateNotFound is wrapped by render errors of missing templates (errors.Is), e.g. to respond with 404.
+// Options.OnMissingTemplate returns it to fall back to the normal not-found error.
+var ErrTemplateNotFound = errors.New("wutrender: template not found")
+
+// ErrLayoutNotFound is wrapped by render errors of a missing layout template (errors.Is)
+var ErrLayoutNotFound = errors.New("wutrender: layout not found")
+
+// notFound returns ErrTemplateNotFound error of fullName
+func notFound(fullName string) error {
+	return fmt.Errorf("%w: %q", ErrTemplateNotFound, fullName)
+}
+
+// ParseError is returned by NewE, InitErr and Reload for a template which failed to parse
+type ParseError struct {
+	// File path, or the template name for sources without a file
+	Path string
+	// Line of the error, 0 if unknown
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("wutrender: %s:%d: %v", e.Path, e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("wutrender: %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Line of "template: users/show.html:3: ..." parse errors
+var errorLine = regexp.MustCompile(`^template: [^:]+:(\d+):`)
+
+// newParseError wraps a parse error of src
+func newParseError(src Source, err error) *ParseError {
+	e := &ParseError{Path: src.path, Err: err}
+	if e.Path == "" {
+		e.Path = src.Name
+	}
+
+	if m := errorLine.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+	}
+
+	return e
+}
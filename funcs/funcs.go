@@ -0,0 +1,407 @@
+// Package funcs is a library of general template helpers: strings, dates, math, lists and maps, default values.
+// wutrender installs it with Options.EnableBuiltins, other html/template users with t.Funcs(funcs.FuncMap()).
+package funcs
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Clock used by now, replaced in tests
+var timeNow = time.Now
+
+// FuncMap returns the helpers. The string, list and default helpers take the value they work on last,
+// so {{ .Title | truncate 20 }} and {{ .Name | default "anonymous" }} read naturally in pipelines.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		// strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":     func(n int, s string) string { return strings.Repeat(s, n) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"truncate":   truncate,
+		"quote":      strconv.Quote,
+		"toString":   toString,
+
+		// dates
+		"now":      func() time.Time { return timeNow() },
+		"date":     date,
+		"toUnix":   func(t time.Time) int64 { return t.Unix() },
+		"fromUnix": func(sec int64) time.Time { return time.Unix(sec, 0) },
+		"addDays":  func(days int, t time.Time) time.Time { return t.AddDate(0, 0, days) },
+		"duration": func(sec int64) string { return (time.Duration(sec) * time.Second).String() },
+
+		// math
+		"add": add,
+		"sub": sub,
+		"mul": mul,
+		"div": div,
+		"mod": mod,
+		"max": maxInt,
+		"min": minInt,
+
+		// lists and maps
+		"list":    list,
+		"first":   first,
+		"last":    last,
+		"append":  appendList,
+		"uniq":    uniq,
+		"sortStr": sortStrings,
+		"get":     get,
+		"set":     set,
+		"hasKey":  hasKey,
+		"keys":    keys,
+
+		// default values
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary":  ternary,
+	}
+}
+
+// title uppercases the first letter of every word
+func title(s string) string {
+	prev := ' '
+
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) {
+			return unicode.ToTitle(r)
+		}
+
+		return r
+	}, s)
+}
+
+// join joins the elements of a slice (strings or any values) with sep: {{ join ", " .Tags }}
+func join(sep string, list interface{}) (string, error) {
+	items, err := toSlice(list)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = toString(item)
+	}
+
+	return strings.Join(parts, sep), nil
+}
+
+// truncate cuts s to n runes, ending it with "…" if it was longer
+func truncate(n int, s string) string {
+	if n < 1 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	runes := []rune(s)
+
+	return strings.TrimRightFunc(string(runes[:n-1]), unicode.IsSpace) + "…"
+}
+
+// toString formats v with fmt, nil becomes ""
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+// date formats a time.Time, *time.Time or unix seconds with a Go layout: {{ date "2006-01-02" .CreatedAt }}
+func date(layout string, v interface{}) (string, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case *time.Time:
+		if t == nil {
+			return "", nil
+		}
+		return t.Format(layout), nil
+	case int, int32, int64:
+		sec, _ := toInt64(t)
+		return time.Unix(sec, 0).Format(layout), nil
+	}
+
+	return "", fmt.Errorf("funcs: date expects time.Time or unix seconds, got %T", v)
+}
+
+// toInt64 converts integer kinds, floats are truncated
+func toInt64(v interface{}) (int64, error) {
+	val := reflect.ValueOf(v)
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(val.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return int64(val.Float()), nil
+	case reflect.String:
+		return strconv.ParseInt(val.String(), 10, 64)
+	}
+
+	return 0, fmt.Errorf("funcs: expected a number, got %T", v)
+}
+
+// ints converts the arguments of math helpers
+func ints(a, b interface{}) (int64, int64, error) {
+	x, err := toInt64(a)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := toInt64(b)
+
+	return x, y, err
+}
+
+func add(a, b interface{}) (int64, error) {
+	x, y, err := ints(a, b)
+	return x + y, err
+}
+
+func sub(a, b interface{}) (int64, error) {
+	x, y, err := ints(a, b)
+	return x - y, err
+}
+
+func mul(a, b interface{}) (int64, error) {
+	x, y, err := ints(a, b)
+	return x * y, err
+}
+
+func div(a, b interface{}) (int64, error) {
+	x, y, err := ints(a, b)
+	if err == nil && y == 0 {
+		err = fmt.Errorf("funcs: division by zero")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return x / y, nil
+}
+
+func mod(a, b interface{}) (int64, error) {
+	x, y, err := ints(a, b)
+	if err == nil && y == 0 {
+		err = fmt.Errorf("funcs: division by zero")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return x % y, nil
+}
+
+func maxInt(a interface{}, rest ...interface{}) (int64, error) {
+	m, err := toInt64(a)
+	for _, v := range rest {
+		if err != nil {
+			break
+		}
+
+		var n int64
+		n, err = toInt64(v)
+		if n > m {
+			m = n
+		}
+	}
+
+	return m, err
+}
+
+func minInt(a interface{}, rest ...interface{}) (int64, error) {
+	m, err := toInt64(a)
+	for _, v := range rest {
+		if err != nil {
+			break
+		}
+
+		var n int64
+		n, err = toInt64(v)
+		if n < m {
+			m = n
+		}
+	}
+
+	return m, err
+}
+
+// toSlice returns the elements of a slice or array
+func toSlice(v interface{}) ([]interface{}, error) {
+	if items, ok := v.([]interface{}); ok {
+		return items, nil
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, fmt.Errorf("funcs: expected a list, got %T", v)
+	}
+
+	items := make([]interface{}, val.Len())
+	for i := range items {
+		items[i] = val.Index(i).Interface()
+	}
+
+	return items, nil
+}
+
+// list builds a list: {{ range list "a" "b" "c" }}
+func list(items ...interface{}) []interface{} {
+	return items
+}
+
+// first returns the first element of a list, nil for an empty one
+func first(v interface{}) (interface{}, error) {
+	items, err := toSlice(v)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+
+	return items[0], nil
+}
+
+// last returns the last element of a list, nil for an empty one
+func last(v interface{}) (interface{}, error) {
+	items, err := toSlice(v)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+
+	return items[len(items)-1], nil
+}
+
+// appendList returns a new list with item after the elements of v: {{ $tags = append $tags "new" }}
+func appendList(v interface{}, item interface{}) ([]interface{}, error) {
+	items, err := toSlice(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(make([]interface{}, 0, len(items)+1), items...), item), nil
+}
+
+// uniq returns the elements of a list without repeats, in first seen order
+func uniq(v interface{}) ([]interface{}, error) {
+	items, err := toSlice(v)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[interface{}]bool{}
+	unique := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if item != nil && !reflect.TypeOf(item).Comparable() {
+			return nil, fmt.Errorf("funcs: uniq can't compare %T", item)
+		}
+		if !seen[item] {
+			seen[item] = true
+			unique = append(unique, item)
+		}
+	}
+
+	return unique, nil
+}
+
+// sortStrings returns the elements of a list as sorted strings
+func sortStrings(v interface{}) ([]string, error) {
+	items, err := toSlice(v)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]string, len(items))
+	for i, item := range items {
+		sorted[i] = toString(item)
+	}
+	sort.Strings(sorted)
+
+	return sorted, nil
+}
+
+// get returns the value of key in a map, nil if it's missing
+func get(key string, m map[string]interface{}) interface{} {
+	return m[key]
+}
+
+// set stores val under key in m and returns m, so it can be used in a pipeline: {{ $_ := set "page" 2 $params }}
+func set(key string, val interface{}, m map[string]interface{}) map[string]interface{} {
+	m[key] = val
+
+	return m
+}
+
+// hasKey reports whether m has key
+func hasKey(key string, m map[string]interface{}) bool {
+	_, ok := m[key]
+
+	return ok
+}
+
+// keys returns the sorted keys of m
+func keys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// empty reports whether v is nil, false, 0, "" or an empty list or map
+func empty(v interface{}) bool {
+	truth, _ := template.IsTrue(v)
+
+	return !truth
+}
+
+// defaultValue returns v unless it's empty: {{ .Name | default "anonymous" }}
+func defaultValue(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || empty(v[0]) {
+		return def
+	}
+
+	return v[0]
+}
+
+// coalesce returns the first non-empty value, nil if all are empty
+func coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !empty(v) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// ternary returns yes if cond is true, no otherwise: {{ ternary "on" "off" .Enabled }}
+func ternary(yes, no interface{}, cond bool) interface{} {
+	if cond {
+		return yes
+	}
+
+	return no
+}
@@ -0,0 +1,92 @@
+package funcs
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, text string, binding interface{}) (string, error) {
+	tmpl, err := template.New("test").Funcs(FuncMap()).Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	err = tmpl.Execute(buf, binding)
+
+	return buf.String(), err
+}
+
+func Test_Strings(t *testing.T) {
+	out, err := render(t, `{{ upper "a" }} {{ "hello world" | title }} {{ "  x " | trim }} {{ "a-b-c" | replace "-" "_" }}`, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, out, "A Hello World x a_b_c")
+
+	out, _ = render(t, `{{ join ", " (split "," "a,b,c") }}|{{ join "-" .Nums }}|{{ if contains "ell" "hello" }}yes{{ end }}`, map[string]interface{}{"Nums": []int{1, 2}})
+	assert.Equal(t, out, "a, b, c|1-2|yes")
+
+	assert.Equal(t, truncate(6, "hello world"), "hello…")
+	assert.Equal(t, truncate(5, "hello world"), "hell…")
+	assert.Equal(t, truncate(20, "hello"), "hello")
+	assert.Equal(t, truncate(3, "привет"), "пр…")
+	assert.Equal(t, truncate(0, "hello"), "")
+}
+
+func Test_Dates(t *testing.T) {
+	created := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+
+	out, err := render(t, `{{ date "2006-01-02" .At }} {{ date "Jan 2" (addDays 1 .At) }} {{ duration 3725 }}`, map[string]interface{}{"At": created})
+	assert.Nil(t, err)
+	assert.Equal(t, out, "2024-03-05 Mar 6 1h2m5s")
+
+	s, err := date("2006", created.Unix())
+	assert.Nil(t, err)
+	assert.Equal(t, s, "2024")
+
+	_, err = date("2006", "yesterday")
+	assert.NotNil(t, err)
+
+	timeNow = func() time.Time { return created }
+	defer func() { timeNow = time.Now }()
+
+	out, _ = render(t, `{{ date "15:04" now }}`, nil)
+	assert.Equal(t, out, created.Local().Format("15:04"))
+}
+
+func Test_Math(t *testing.T) {
+	out, err := render(t, `{{ add 1 2 }} {{ sub 5 .N }} {{ mul 2 3 }} {{ div 7 2 }} {{ mod 7 2 }} {{ max 1 9 3 }} {{ min 4 2 8 }}`, map[string]interface{}{"N": uint8(3)})
+	assert.Nil(t, err)
+	assert.Equal(t, out, "3 2 6 3 1 9 2")
+
+	_, err = render(t, `{{ div 1 0 }}`, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "division by zero")
+
+	_, err = render(t, `{{ add 1 "x" }}`, nil)
+	assert.NotNil(t, err)
+}
+
+func Test_ListsAndMaps(t *testing.T) {
+	out, err := render(t, `{{ range list "b" "a" "b" | uniq }}{{ . }}{{ end }}|{{ first .Tags }}{{ last .Tags }}|{{ join "," (append .Tags "z") }}|{{ sortStr .Tags }}`,
+		map[string]interface{}{"Tags": []string{"y", "x"}})
+	assert.Nil(t, err)
+	assert.Equal(t, out, "ba|yx|y,x,z|[x y]")
+
+	m := map[string]interface{}{"a": 1}
+	out, err = render(t, `{{ $_ := set "b" 2 . }}{{ keys . }} {{ get "b" . }} {{ hasKey "c" . }}`, m)
+	assert.Nil(t, err)
+	assert.Equal(t, out, "[a b] 2 false")
+
+	_, err = render(t, `{{ first "abc" }}`, nil)
+	assert.NotNil(t, err)
+}
+
+func Test_Defaults(t *testing.T) {
+	out, err := render(t, `{{ .Name | default "anonymous" }} {{ .Missing | default "none" }} {{ coalesce "" 0 "x" }} {{ ternary "on" "off" .On }} {{ empty .Items }}`,
+		map[string]interface{}{"Name": "Ann", "Missing": "", "On": false, "Items": []string{}})
+	assert.Nil(t, err)
+	assert.Equal(t, out, "Ann none x off true")
+}
@@ -0,0 +1,84 @@
+package wutrender
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PathHandlerOptions configures Renderer.PathHandler
+type PathHandlerOptions struct {
+	// URL prefix stripped from the request path. Defaults to "".
+	Prefix string
+	// Template folder the paths are resolved in, e.g. "pages" maps "/about" to "pages/about". Defaults to "".
+	Root string
+	// Template name for paths ending with "/". Defaults to "index".
+	Index string
+	// Binding for the request. Defaults to nil binding.
+	Binding func(r *http.Request) interface{}
+}
+
+// PathHandler returns http.Handler which renders the HTML template named after the URL path
+// ("/docs/intro" renders "docs/intro.html") and responds with 404 if there is no such template.
+// Partials ("_name") and paths with ".." segments are never rendered.
+func (r *Renderer) PathHandler(opts ...PathHandlerOptions) http.Handler {
+	var opt PathHandlerOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.Index == "" {
+		opt.Index = "index"
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		name, ok := pathToName(req.URL.Path, opt)
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+
+		tmpl := r.Copy().SetRequest(req)
+		if !tmpl.exists(name+".html") && tmpl.engineOf(name+".html") == nil {
+			http.NotFound(rw, req)
+			return
+		}
+
+		var binding interface{}
+		if opt.Binding != nil {
+			binding = opt.Binding(req)
+		}
+
+		tmpl.WriteHTML(rw, http.StatusOK, name, binding)
+	})
+}
+
+// pathToName maps URL path to template name, ok is false for paths outside of the templates
+func pathToName(urlPath string, opt PathHandlerOptions) (string, bool) {
+	if !strings.HasPrefix(urlPath, opt.Prefix) {
+		return "", false
+	}
+	urlPath = strings.TrimPrefix(urlPath, opt.Prefix)
+
+	// "/docs" serves "/docs/..." but not "/docssecret"
+	if urlPath != "" && !strings.HasSuffix(opt.Prefix, "/") && !strings.HasPrefix(urlPath, "/") {
+		return "", false
+	}
+
+	if urlPath == "" || strings.HasSuffix(urlPath, "/") {
+		urlPath += opt.Index
+	}
+
+	if strings.Contains(urlPath, "\\") || strings.Contains(urlPath, "\x00") {
+		return "", false
+	}
+
+	segments := strings.Split(strings.TrimPrefix(urlPath, "/"), "/")
+	for _, s := range segments {
+		if s == "" || s == "." || s == ".." || strings.HasPrefix(s, "_") {
+			return "", false
+		}
+	}
+
+	return path.Join(opt.Root, strings.Join(segments, "/")), true
+}
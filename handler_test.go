@@ -0,0 +1,72 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_PathHandler(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	h := r.PathHandler(PathHandlerOptions{
+		Prefix: "/site",
+		Root:   "pages",
+		Binding: func(req *http.Request) interface{} {
+			return req.URL.Query().Get("q")
+		},
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := get("/site/?q=page")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Body.String(), "<h1>Home page</h1>")
+
+	rw = get("/site")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Body.String(), "<h1>Home </h1>")
+
+	rw = get("/site/docs/intro")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Body.String(), "<h1>Intro</h1>")
+
+	assert.Equal(t, get("/site/docs/missing").Code, 404)
+	assert.Equal(t, get("/other/docs/intro").Code, 404)
+	assert.Equal(t, get("/sitedocs/intro").Code, 404)
+}
+
+func Test_PathToName(t *testing.T) {
+	opt := PathHandlerOptions{Root: "pages", Index: "index"}
+
+	name, ok := pathToName("/docs/", opt)
+	assert.True(t, ok)
+	assert.Equal(t, name, "pages/docs/index")
+
+	for _, p := range []string{"/../base/hello", "/docs/../../base/hello", "//etc/passwd", "/docs/_partial", "/a\\..\\b", "/docs/./intro"} {
+		_, ok = pathToName(p, opt)
+		assert.False(t, ok, p)
+	}
+
+	// the prefix matches whole path segments
+	opt.Prefix = "/docs"
+	_, ok = pathToName("/docssecret", opt)
+	assert.False(t, ok)
+
+	name, ok = pathToName("/docs/intro", opt)
+	assert.True(t, ok)
+	assert.Equal(t, name, "pages/intro")
+
+	opt.Prefix = "/docs/"
+	name, ok = pathToName("/docs/intro", opt)
+	assert.True(t, ok)
+	assert.Equal(t, name, "pages/intro")
+}
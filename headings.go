@@ -0,0 +1,187 @@
+package wutrender
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/net/html"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// heading is a <h1>-<h6> tag found by headingAnchors
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+// HeadingAnchors adds slugified id attributes to <h1>-<h6> tags without one:
+// <h2>Getting Started</h2> becomes <h2 id="getting-started">Getting Started</h2>.
+// Ids already used in the document get a "-2", "-3", ... suffix.
+func HeadingAnchors(b []byte) []byte {
+	out, _ := headingAnchors(b)
+	return out
+}
+
+// headingAnchors adds ids to headings and returns them in document order
+func headingAnchors(b []byte) ([]byte, []heading) {
+	used := map[string]bool{}
+
+	z := html.NewTokenizer(bytes.NewReader(b))
+	for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			if id := attrValue(z.Token().Attr, "id"); id != "" {
+				used[id] = true
+			}
+		}
+	}
+
+	var headings []heading
+	out := new(bytes.Buffer)
+
+	z = html.NewTokenizer(bytes.NewReader(b))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.Bytes(), headings
+		}
+
+		raw := z.Raw()
+
+		if tt != html.StartTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		// copy raw bytes since Token() may reuse the buffer
+		start := append([]byte(nil), raw...)
+		token := z.Token()
+		level := headingLevel(token.Data)
+
+		if level == 0 {
+			out.Write(start)
+			continue
+		}
+
+		// buffer the heading content to get its text
+		content := new(bytes.Buffer)
+		var text []string
+		for {
+			tt = z.Next()
+			if tt == html.ErrorToken {
+				break
+			}
+
+			content.Write(z.Raw())
+
+			if tt == html.TextToken {
+				text = append(text, string(z.Text()))
+			}
+			if tt == html.EndTagToken {
+				if name, _ := z.TagName(); headingLevel(string(name)) == level {
+					break
+				}
+			}
+		}
+
+		h := heading{
+			level: level,
+			id:    attrValue(token.Attr, "id"),
+			text:  strings.Join(strings.Fields(strings.Join(text, "")), " "),
+		}
+
+		if h.id == "" {
+			h.id = uniqueID(slugify(h.text), used)
+		}
+
+		if h.id != "" && attrValue(token.Attr, "id") == "" {
+			token.Attr = append(token.Attr, html.Attribute{Key: "id", Val: h.id})
+			out.WriteString(token.String())
+		} else {
+			out.Write(start)
+		}
+		out.Write(content.Bytes())
+
+		if h.id != "" {
+			headings = append(headings, h)
+		}
+	}
+}
+
+// headingLevel returns n of <hn> tag name or 0
+func headingLevel(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+
+	return 0
+}
+
+// attrValue returns the value of key attribute or ""
+func attrValue(attrs []html.Attribute, key string) string {
+	for _, attr := range attrs {
+		if attr.Namespace == "" && attr.Key == key {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
+
+// uniqueID returns id, or id with the first free "-n" suffix, and marks it used
+func uniqueID(id string, used map[string]bool) string {
+	if id == "" {
+		return ""
+	}
+
+	unique := id
+	for n := 2; used[unique]; n++ {
+		unique = id + "-" + strconv.Itoa(n)
+	}
+	used[unique] = true
+
+	return unique
+}
+
+// toc returns a nested list of links to the headings of rendered html: {{ toc .Content }}
+func toc(content interface{}) (template.HTML, error) {
+	var b []byte
+	switch v := content.(type) {
+	case template.HTML:
+		b = []byte(v)
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return "", fmt.Errorf("wutrender: toc expects rendered html, got %T", content)
+	}
+
+	_, headings := headingAnchors(b)
+
+	buf := new(bytes.Buffer)
+	var levels []int
+
+	for _, h := range headings {
+		if len(levels) == 0 || h.level > levels[len(levels)-1] {
+			buf.WriteString("<ul><li>")
+			levels = append(levels, h.level)
+		} else {
+			for len(levels) > 1 && levels[len(levels)-2] >= h.level {
+				buf.WriteString("</li></ul>")
+				levels = levels[:len(levels)-1]
+			}
+			buf.WriteString("</li><li>")
+			levels[len(levels)-1] = h.level
+		}
+
+		fmt.Fprintf(buf, `<a href="#%s">%s</a>`, template.HTMLEscapeString(h.id), template.HTMLEscapeString(h.text))
+	}
+
+	for range levels {
+		buf.WriteString("</li></ul>")
+	}
+
+	return template.HTML(buf.String()), nil
+}
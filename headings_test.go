@@ -0,0 +1,55 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_HeadingAnchors(t *testing.T) {
+	in := `<h1>Guide</h1><h2 class="x">Install <em>it</em></h2><p id="install-it">p</p><h3 id="req">Requirements</h3><h2>Guide</h2><h4></h4>`
+
+	assert.Equal(t, string(HeadingAnchors([]byte(in))),
+		`<h1 id="guide">Guide</h1><h2 class="x" id="install-it-2">Install <em>it</em></h2><p id="install-it">p</p>`+
+			`<h3 id="req">Requirements</h3><h2 id="guide-2">Guide</h2><h4></h4>`)
+}
+
+func Test_TOC(t *testing.T) {
+	html, err := toc(`<h1>Guide</h1><h2>Install</h2><h3>Linux &amp; Mac</h3><h2>Usage</h2><h1>FAQ</h1>`)
+	assert.Nil(t, err)
+	assert.Equal(t, string(html), `<ul><li><a href="#guide">Guide</a>`+
+		`<ul><li><a href="#install">Install</a><ul><li><a href="#linux-mac">Linux &amp; Mac</a></li></ul></li>`+
+		`<li><a href="#usage">Usage</a></li></ul></li>`+
+		`<li><a href="#faq">FAQ</a></li></ul>`)
+
+	html, _ = toc("<p>no headings</p>")
+	assert.Equal(t, string(html), "")
+
+	_, err = toc(42)
+	assert.NotNil(t, err)
+}
+
+func Test_HeadingAnchorsOption(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<h1>Docs</h1><nav>{{ toc .Content }}</nav>{{ yield }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`<h2>Docs</h2><h3>{{ .Title }}</h3>`), 0644)
+
+	r := New(Options{
+		Directory:      dir,
+		Layout:         "layout",
+		HeadingAnchors: true,
+		SecondPass: func(content []byte, binding interface{}) interface{} {
+			return map[string]interface{}{"Content": string(content)}
+		},
+	})
+
+	html, err := r.Copy().HTML("page", map[string]string{"Title": "Setup"})
+	assert.Nil(t, err)
+	// content ids are set before the layout, so the layout heading gets the suffix
+	assert.Equal(t, html.String(), `<h1 id="docs-2">Docs</h1>`+
+		`<nav><ul><li><a href="#docs">Docs</a><ul><li><a href="#setup">Setup</a></li></ul></li></ul></nav>`+
+		`<h2 id="docs">Docs</h2><h3 id="setup">Setup</h3>`)
+}
@@ -0,0 +1,563 @@
+package wutrender
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultFuncs are helper functions available in every template.
+// Options.Funcs are installed after them, so user helpers win on name conflicts.
+var DefaultFuncs = template.FuncMap{
+	"slugify":      slugify,
+	"dict":         dict,
+	"classMap":     classMap,
+	"timeAgo":      timeAgo,
+	"highlight":    highlight,
+	"sortedKeys":   sortedKeys,
+	"sortedItems":  sortedItems,
+	"metaTags":     metaTags,
+	"toc":          toc,
+	"humanBytes":   humanBytes,
+	"humanBytesSI": humanBytesSI,
+}
+
+// Clock used by timeAgo without Options.Now and by MemoryStore, replaced in tests
+var timeNow = time.Now
+
+// ScaffoldFuncs are opinionated admin helpers, not installed by default:
+//
+//	Funcs: []template.FuncMap{wutrender.ScaffoldFuncs}
+var ScaffoldFuncs = template.FuncMap{
+	"autoTable": autoTable,
+}
+
+// FormFuncs render validation errors from an "Errors map[string][]string" field of the binding,
+// not installed by default:
+//
+//	{{ if hasError "email" . }}<p>{{ fieldError "email" . }}</p>{{ end }}
+var FormFuncs = template.FuncMap{
+	"fieldError": fieldError,
+	"hasError":   hasError,
+}
+
+// slugify lowercases s and joins its letters and digits with single hyphens:
+// "My Post, Title!" becomes "my-post-title"
+func slugify(s string) string {
+	var b bytes.Buffer
+	hyphen := false
+
+	for _, c := range strings.ToLower(s) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+
+	return b.String()
+}
+
+// dict builds a map from "key" value pairs: {{ dict "active" true "title" .Title }}
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("wutrender: dict takes key-value pairs, got %v", pairs)
+	}
+
+	m, err := mapFromPairs(pairs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.(map[string]interface{}), nil
+}
+
+// classMap returns class attribute with the keys of truthy values in sorted order:
+// <li {{ classMap (dict "active" .IsActive "disabled" .IsDisabled) }}>
+func classMap(conditions map[string]interface{}) template.HTMLAttr {
+	names := make([]string, 0, len(conditions))
+
+	for name, cond := range conditions {
+		if truth, _ := template.IsTrue(cond); truth {
+			names = append(names, template.HTMLEscapeString(name))
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+
+	return template.HTMLAttr(`class="` + strings.Join(names, " ") + `"`)
+}
+
+// metaTags returns description, Open Graph and Twitter card <meta> tags for the Title, Description, Image
+// and URL fields of a struct or map, tags of empty fields are omitted: {{ metaTags .Meta }}
+func metaTags(meta interface{}) template.HTML {
+	title := metaField(meta, "Title")
+	description := metaField(meta, "Description")
+	image := metaField(meta, "Image")
+	url := metaField(meta, "URL")
+
+	var tags []string
+	tag := func(attr, key, value string) {
+		if value != "" {
+			tags = append(tags, fmt.Sprintf(`<meta %s="%s" content="%s">`, attr, key, template.HTMLEscapeString(value)))
+		}
+	}
+
+	tag("name", "description", description)
+	tag("property", "og:title", title)
+	tag("property", "og:description", description)
+	tag("property", "og:image", image)
+	tag("property", "og:url", url)
+
+	if len(tags) == 0 {
+		return ""
+	}
+
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+	tag("name", "twitter:card", card)
+	tag("name", "twitter:title", title)
+	tag("name", "twitter:description", description)
+	tag("name", "twitter:image", image)
+
+	return template.HTML(strings.Join(tags, "\n"))
+}
+
+// metaField returns the string value of a map key or struct field, "" if there is none
+func metaField(meta interface{}, name string) string {
+	switch m := meta.(type) {
+	case map[string]string:
+		return m[name]
+	case map[string]interface{}:
+		if v, ok := m[name]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(meta))
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+
+	f := v.FieldByName(name)
+	if !f.IsValid() || !f.CanInterface() {
+		return ""
+	}
+
+	return fmt.Sprint(f.Interface())
+}
+
+// humanBytes formats a size with binary (1024) units: 1536 is "1.5 KB", 1023 is "1023 B"
+func humanBytes(n int64) string {
+	return formatBytes(n, 1024, []string{"KB", "MB", "GB", "TB", "PB", "EB"})
+}
+
+// humanBytesSI formats a size with decimal (1000) units: 1500 is "1.5 kB", 1000000 is "1 MB"
+func humanBytesSI(n int64) string {
+	return formatBytes(n, 1000, []string{"kB", "MB", "GB", "TB", "PB", "EB"})
+}
+
+// formatBytes formats n with one decimal in the largest unit below its size
+func formatBytes(n int64, base float64, units []string) string {
+	if math.Abs(float64(n)) < base {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	round := func(v float64) float64 { return math.Round(v*10) / 10 }
+
+	v := float64(n) / base
+	i := 0
+	for math.Abs(round(v)) >= base && i < len(units)-1 {
+		v /= base
+		i++
+	}
+
+	return strconv.FormatFloat(round(v), 'f', -1, 64) + " " + units[i]
+}
+
+// timeAgo returns relative time: "just now", "5 minutes ago", "in 2 days". Zero time is ""
+func timeAgo(t time.Time) string {
+	return timeAgoFrom(timeNow(), t)
+}
+
+// timeAgoFrom is timeAgo with now as the current time
+func timeAgoFrom(now, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	if d < time.Minute {
+		return "just now"
+	}
+
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+
+	var s string
+	for _, u := range units {
+		if n := int64(d / u.d); n > 0 {
+			s = fmt.Sprintf("%d %s", n, u.name)
+			if n > 1 {
+				s += "s"
+			}
+			break
+		}
+	}
+
+	if future {
+		return "in " + s
+	}
+
+	return s + " ago"
+}
+
+// highlight escapes text and wraps case-insensitive matches of terms (strings or []string) in <mark>.
+// Overlapping and adjacent matches share one <mark>.
+func highlight(text string, terms ...interface{}) (template.HTML, error) {
+	var words []string
+	for _, term := range terms {
+		switch v := term.(type) {
+		case string:
+			words = append(words, v)
+		case []string:
+			words = append(words, v...)
+		default:
+			return "", fmt.Errorf("wutrender: highlight terms should be strings, got %T", term)
+		}
+	}
+
+	marked := make([]bool, len(text))
+	for i := range text {
+		for _, w := range words {
+			if w == "" {
+				continue
+			}
+			for j := i; j < i+matchFold(text[i:], w); j++ {
+				marked[j] = true
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	start := 0
+	for start < len(text) {
+		end := start
+		for end < len(text) && marked[end] == marked[start] {
+			end++
+		}
+
+		if marked[start] {
+			buf.WriteString("<mark>" + template.HTMLEscapeString(text[start:end]) + "</mark>")
+		} else {
+			buf.WriteString(template.HTMLEscapeString(text[start:end]))
+		}
+
+		start = end
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
+// matchFold returns the length in bytes of the case-insensitive match of term at the start of s or 0
+func matchFold(s, term string) int {
+	n := 0
+
+	for _, tr := range term {
+		if n >= len(s) {
+			return 0
+		}
+
+		sr, size := utf8.DecodeRuneInString(s[n:])
+		if !equalFoldRune(sr, tr) {
+			return 0
+		}
+
+		n += size
+	}
+
+	return n
+}
+
+// equalFoldRune reports whether runes are equal under Unicode case folding
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mapItem is an element of sortedItems
+type mapItem struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// sortedKeys returns keys of a map with string, integer or float keys in sorted order
+func sortedKeys(m interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return nil, fmt.Errorf("wutrender: sortedKeys expects a map, got %T", m)
+	}
+
+	keys := v.MapKeys()
+
+	var less func(i, j int) bool
+	switch v.Type().Key().Kind() {
+	case reflect.String:
+		less = func(i, j int) bool { return keys[i].String() < keys[j].String() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		less = func(i, j int) bool { return keys[i].Int() < keys[j].Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		less = func(i, j int) bool { return keys[i].Uint() < keys[j].Uint() }
+	case reflect.Float32, reflect.Float64:
+		less = func(i, j int) bool { return keys[i].Float() < keys[j].Float() }
+	default:
+		return nil, fmt.Errorf("wutrender: sortedKeys can't sort keys of %T", m)
+	}
+
+	sort.Slice(keys, less)
+
+	sorted := make([]interface{}, len(keys))
+	for i, k := range keys {
+		sorted[i] = k.Interface()
+	}
+
+	return sorted, nil
+}
+
+// sortedItems returns {Key, Value} pairs of a map in sorted key order:
+// {{ range sortedItems .Data }}{{ .Key }}={{ .Value }}{{ end }}
+func sortedItems(m interface{}) ([]mapItem, error) {
+	keys, err := sortedKeys(m)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(m)
+	items := make([]mapItem, len(keys))
+	for i, k := range keys {
+		items[i] = mapItem{k, v.MapIndex(reflect.ValueOf(k)).Interface()}
+	}
+
+	return items, nil
+}
+
+// autoTable renders a slice of structs as <table> with exported field names in the header row
+func autoTable(items interface{}) (template.HTML, error) {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("wutrender: autoTable expects a slice, got %T", items)
+	}
+
+	if v.Len() == 0 {
+		return "", nil
+	}
+
+	elem := v.Type().Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return "", fmt.Errorf("wutrender: autoTable expects a slice of structs, got %T", items)
+	}
+
+	var fields []int
+	buf := bytes.NewBufferString("<table><thead><tr>")
+
+	for i := 0; i < elem.NumField(); i++ {
+		if f := elem.Field(i); f.PkgPath == "" {
+			fields = append(fields, i)
+			buf.WriteString("<th>" + template.HTMLEscapeString(f.Name) + "</th>")
+		}
+	}
+
+	buf.WriteString("</tr></thead><tbody>")
+
+	for i := 0; i < v.Len(); i++ {
+		item := reflect.Indirect(v.Index(i))
+		buf.WriteString("<tr>")
+
+		for _, f := range fields {
+			buf.WriteString("<td>")
+			if item.IsValid() {
+				buf.WriteString(template.HTMLEscapeString(fmt.Sprint(item.Field(f).Interface())))
+			}
+			buf.WriteString("</td>")
+		}
+
+		buf.WriteString("</tr>")
+	}
+
+	buf.WriteString("</tbody></table>")
+
+	return template.HTML(buf.String()), nil
+}
+
+// fieldError returns the first error of field, "" if there is none
+func fieldError(field string, data interface{}) string {
+	if errs := formErrors(data)[field]; len(errs) > 0 {
+		return errs[0]
+	}
+
+	return ""
+}
+
+// hasError reports whether field has any errors
+func hasError(field string, data interface{}) bool {
+	return len(formErrors(data)[field]) > 0
+}
+
+// formErrors finds the errors map in data: the map itself, an "Errors" map key or an Errors struct field
+func formErrors(data interface{}) map[string][]string {
+	switch d := data.(type) {
+	case map[string][]string:
+		return d
+	case map[string]interface{}:
+		errs, _ := d["Errors"].(map[string][]string)
+		return errs
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	f := v.FieldByName("Errors")
+	if !f.IsValid() || !f.CanInterface() {
+		return nil
+	}
+
+	errs, _ := f.Interface().(map[string][]string)
+	return errs
+}
+
+// optionFuncs returns helpers configured by the Renderer options
+func (r *Renderer) optionFuncs() template.FuncMap {
+	return template.FuncMap{
+		"env":      r.env,
+		"route":    r.route,
+		"currency": currencyFunc(r.options.Locale),
+		"cached":   r.cached,
+		"img":      r.img,
+		"timeAgo":  r.timeAgo,
+		// "highlight" is taken by search term highlighting
+		"highlightCode": r.highlightCode,
+	}
+}
+
+// timeAgo is the timeAgo helper with the Options.Now clock
+func (r *Renderer) timeAgo(t time.Time) string {
+	if r.options.Now != nil {
+		return timeAgoFrom(r.options.Now(), t)
+	}
+
+	return timeAgo(t)
+}
+
+// env returns the value of a whitelisted environment variable or ""
+func (r *Renderer) env(key string) string {
+	if r.options.EnvFunc != nil {
+		v, _ := r.options.EnvFunc(key)
+		return v
+	}
+
+	for _, k := range r.options.EnvWhitelist {
+		if k == key {
+			return os.Getenv(key)
+		}
+	}
+
+	return ""
+}
+
+// cached returns the fragment stored with Renderer.SetCachedFragment: {{ cached "main-nav" }}
+func (r *Renderer) cached(key string) (template.HTML, error) {
+	r.fragmentsMu.RLock()
+	html, ok := r.fragments[key]
+	r.fragmentsMu.RUnlock()
+
+	if !ok && r.options.StrictFragments {
+		return "", fmt.Errorf("wutrender: cached fragment %q is not set", key)
+	}
+
+	return html, nil
+}
+
+// img returns an <img> tag with width and height from Options.ImageInfo when known:
+// {{ img "/logo.png" "Logo" }}. Unsafe URLs are replaced with "#ZgotmplZ" like in html/template.
+func (r *Renderer) img(src, alt string) template.HTML {
+	if u, err := url.Parse(src); err != nil || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		src = "#ZgotmplZ"
+	}
+
+	tag := fmt.Sprintf(`<img src="%s" alt="%s"`, template.HTMLEscapeString(src), template.HTMLEscapeString(alt))
+
+	if r.options.ImageInfo != nil {
+		if w, h, ok := r.options.ImageInfo(src); ok {
+			tag += fmt.Sprintf(` width="%d" height="%d"`, w, h)
+		}
+	}
+
+	return template.HTML(tag + ">")
+}
+
+// highlightCode highlights code with Options.Highlighter: {{ highlightCode "go" .Snippet }}.
+// Without a highlighter the code is escaped into <pre><code>.
+func (r *Renderer) highlightCode(lang, code string) (template.HTML, error) {
+	if r.options.Highlighter != nil {
+		return r.options.Highlighter(lang, code)
+	}
+
+	return template.HTML("<pre><code>" + template.HTMLEscapeString(code) + "</code></pre>"), nil
+}
+
+// route builds URL of the named route with Options.RouteResolver: {{ route "user.show" .ID }}
+func (r *Renderer) route(name string, args ...interface{}) (string, error) {
+	if r.options.RouteResolver == nil {
+		return "", fmt.Errorf("wutrender: route %q called without Options.RouteResolver", name)
+	}
+
+	return r.options.RouteResolver(name, args...)
+}
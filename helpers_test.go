@@ -0,0 +1,400 @@
+package wutrender
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/8protons/wutrender/funcs"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Slugify(t *testing.T) {
+	assert.Equal(t, slugify("My Post Title"), "my-post-title")
+	assert.Equal(t, slugify("  --Hello,   World!!--  "), "hello-world")
+	assert.Equal(t, slugify("C'est déjà l'été"), "c-est-déjà-l-été")
+	assert.Equal(t, slugify("Привет, мир 2024"), "привет-мир-2024")
+	assert.Equal(t, slugify("?!.,;"), "")
+	assert.Equal(t, slugify(""), "")
+}
+
+func Test_Env(t *testing.T) {
+	os.Setenv("WUTRENDER_BANNER", "sale")
+	os.Setenv("WUTRENDER_SECRET", "s3cr3t")
+	defer os.Unsetenv("WUTRENDER_BANNER")
+	defer os.Unsetenv("WUTRENDER_SECRET")
+
+	r := New(Options{
+		Directory:    "fixtures",
+		EnvWhitelist: []string{"WUTRENDER_BANNER"},
+	})
+
+	assert.Equal(t, r.env("WUTRENDER_BANNER"), "sale")
+	assert.Equal(t, r.env("WUTRENDER_SECRET"), "")
+
+	r = New(Options{
+		Directory: "fixtures",
+		EnvFunc: func(key string) (string, bool) {
+			if key == "FEATURE" {
+				return "on", true
+			}
+			return "", false
+		},
+	})
+
+	assert.Equal(t, r.env("FEATURE"), "on")
+	assert.Equal(t, r.env("WUTRENDER_BANNER"), "")
+}
+
+func Test_EnableBuiltins(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ .Title | upper | truncate 8 }} {{ slugify .Title }} {{ add 1 2 }}`), 0644)
+
+	_, err := NewE(Options{
+		Directory: dir,
+	})
+	assert.NotNil(t, err)
+
+	r := New(Options{
+		Directory:      dir,
+		EnableBuiltins: true,
+		Funcs:          []template.FuncMap{{"add": func(a, b int) int { return a * b }}},
+	})
+
+	html, err := r.Copy().HTML("page", map[string]string{"Title": "Hello World"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "HELLO W… hello-world 2")
+
+	for name := range funcs.FuncMap() {
+		_, ok := DefaultFuncs[name]
+		assert.False(t, ok, name)
+		_, ok = helperFunctions[name]
+		assert.False(t, ok, name)
+	}
+}
+
+func Test_Dict(t *testing.T) {
+	m, err := dict("a", 1, "b", "two")
+	assert.Nil(t, err)
+	assert.Equal(t, m, map[string]interface{}{"a": 1, "b": "two"})
+
+	m, err = dict()
+	assert.Nil(t, err)
+	assert.Equal(t, len(m), 0)
+
+	_, err = dict("a")
+	assert.NotNil(t, err)
+
+	_, err = dict(1, 2)
+	assert.NotNil(t, err)
+}
+
+func Test_ClassMap(t *testing.T) {
+	conditions := map[string]interface{}{
+		"zeta":     true,
+		"active":   1,
+		"disabled": false,
+		"empty":    "",
+		"beta":     "yes",
+		"nil":      nil,
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, classMap(conditions), template.HTMLAttr(`class="active beta zeta"`))
+	}
+
+	assert.Equal(t, classMap(map[string]interface{}{"off": false}), template.HTMLAttr(""))
+
+	tmpl := template.Must(template.New("li").Funcs(DefaultFuncs).Parse(`<li {{ classMap (dict "active" .IsActive "disabled" .IsDisabled) }}>`))
+	buf := new(bytes.Buffer)
+	tmpl.Execute(buf, map[string]bool{"IsActive": true, "IsDisabled": false})
+	assert.Equal(t, buf.String(), `<li class="active">`)
+}
+
+func Test_Cached(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+	r.SetCachedFragment("main-nav", `<nav>Home</nav>`)
+
+	html, err := r.cached("main-nav")
+	assert.Nil(t, err)
+	assert.Equal(t, html, template.HTML(`<nav>Home</nav>`))
+
+	html, err = r.cached("footer")
+	assert.Nil(t, err)
+	assert.Equal(t, html, template.HTML(""))
+
+	r = New(Options{
+		Directory:       "fixtures",
+		StrictFragments: true,
+	})
+
+	_, err = r.cached("footer")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `cached fragment "footer" is not set`)
+}
+
+func Test_Img(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		ImageInfo: func(src string) (int, int, bool) {
+			if src == "/logo.png" {
+				return 120, 40, true
+			}
+			return 0, 0, false
+		},
+	})
+
+	assert.Equal(t, r.img("/logo.png", `ACME "Inc"`), template.HTML(`<img src="/logo.png" alt="ACME &#34;Inc&#34;" width="120" height="40">`))
+	assert.Equal(t, r.img("/a.png?x=1&y=2", ""), template.HTML(`<img src="/a.png?x=1&amp;y=2" alt="">`))
+	assert.Equal(t, r.img("javascript:alert(1)", "x"), template.HTML(`<img src="#ZgotmplZ" alt="x">`))
+
+	tmpl := template.Must(template.New("img").Funcs(r.optionFuncs()).Parse(`{{ img .Src "Logo" }}`))
+	buf := new(bytes.Buffer)
+	tmpl.Execute(buf, map[string]string{"Src": "/logo.png"})
+	assert.Equal(t, buf.String(), `<img src="/logo.png" alt="Logo" width="120" height="40">`)
+}
+
+func Test_HighlightCode(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	html, err := r.highlightCode("go", `if a < b && ok {}`)
+	assert.Nil(t, err)
+	assert.Equal(t, html, template.HTML(`<pre><code>if a &lt; b &amp;&amp; ok {}</code></pre>`))
+
+	r = New(Options{
+		Directory: "fixtures",
+		Highlighter: func(lang, code string) (template.HTML, error) {
+			if lang == "cobol" {
+				return "", fmt.Errorf("unknown language %q", lang)
+			}
+			return template.HTML(`<span class="hl-` + lang + `">` + template.HTMLEscapeString(code) + `</span>`), nil
+		},
+	})
+
+	tmpl := template.Must(template.New("code").Funcs(r.optionFuncs()).Parse(`{{ highlightCode "go" .Snippet }}`))
+	buf := new(bytes.Buffer)
+	tmpl.Execute(buf, map[string]string{"Snippet": "x := <-ch"})
+	assert.Equal(t, buf.String(), `<span class="hl-go">x := &lt;-ch</span>`)
+
+	_, err = r.highlightCode("cobol", "")
+	assert.NotNil(t, err)
+}
+
+func Test_Route(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		RouteResolver: func(name string, args ...interface{}) (string, error) {
+			if name == "user.show" {
+				return fmt.Sprintf("/users/%v", args[0]), nil
+			}
+			return "", fmt.Errorf("unknown route %q", name)
+		},
+	})
+
+	url, err := r.route("user.show", 42)
+	assert.Nil(t, err)
+	assert.Equal(t, url, "/users/42")
+
+	_, err = r.route("user.missing")
+	assert.NotNil(t, err)
+
+	_, err = New(Options{Directory: "fixtures"}).route("user.show", 42)
+	assert.NotNil(t, err)
+}
+
+type tableRow struct {
+	ID     int
+	Name   string
+	secret string
+}
+
+func Test_HumanBytes(t *testing.T) {
+	assert.Equal(t, humanBytes(0), "0 B")
+	assert.Equal(t, humanBytes(1023), "1023 B")
+	assert.Equal(t, humanBytes(1024), "1 KB")
+	assert.Equal(t, humanBytes(1536), "1.5 KB")
+	assert.Equal(t, humanBytes(524288), "512 KB")
+	assert.Equal(t, humanBytes(1000000), "976.6 KB")
+	assert.Equal(t, humanBytes(1048575), "1 MB")
+	assert.Equal(t, humanBytes(1572864), "1.5 MB")
+	assert.Equal(t, humanBytes(-1536), "-1.5 KB")
+	assert.Equal(t, humanBytes(math.MaxInt64), "8 EB")
+
+	assert.Equal(t, humanBytesSI(999), "999 B")
+	assert.Equal(t, humanBytesSI(1000), "1 kB")
+	assert.Equal(t, humanBytesSI(1023), "1 kB")
+	assert.Equal(t, humanBytesSI(1024), "1 kB")
+	assert.Equal(t, humanBytesSI(1000000), "1 MB")
+	assert.Equal(t, humanBytesSI(-2500000000), "-2.5 GB")
+}
+
+func Test_MetaTags(t *testing.T) {
+	type meta struct {
+		Title       string
+		Description string
+		Image       string
+		URL         string
+	}
+
+	html := metaTags(meta{
+		Title:       `Tom & "Jerry"`,
+		Description: "Cartoon",
+		Image:       "https://example.com/tj.png",
+		URL:         "https://example.com/tj",
+	})
+	assert.Equal(t, string(html), `<meta name="description" content="Cartoon">
+<meta property="og:title" content="Tom &amp; &#34;Jerry&#34;">
+<meta property="og:description" content="Cartoon">
+<meta property="og:image" content="https://example.com/tj.png">
+<meta property="og:url" content="https://example.com/tj">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="Tom &amp; &#34;Jerry&#34;">
+<meta name="twitter:description" content="Cartoon">
+<meta name="twitter:image" content="https://example.com/tj.png">`)
+
+	html = metaTags(&meta{Title: "About"})
+	assert.Equal(t, string(html), `<meta property="og:title" content="About">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="About">`)
+
+	html = metaTags(map[string]interface{}{"Description": "Docs", "Image": nil})
+	assert.Equal(t, string(html), `<meta name="description" content="Docs">
+<meta property="og:description" content="Docs">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:description" content="Docs">`)
+
+	assert.Equal(t, metaTags(meta{}), template.HTML(""))
+	assert.Equal(t, metaTags(nil), template.HTML(""))
+}
+
+func Test_FormFuncs(t *testing.T) {
+	type form struct {
+		Email  string
+		Errors map[string][]string
+	}
+	errs := map[string][]string{"email": {"is invalid", "is taken"}}
+
+	assert.Equal(t, fieldError("email", form{Errors: errs}), "is invalid")
+	assert.Equal(t, fieldError("name", &form{Errors: errs}), "")
+	assert.True(t, hasError("email", map[string]interface{}{"Errors": errs}))
+	assert.False(t, hasError("name", map[string]interface{}{"Errors": errs}))
+	assert.False(t, hasError("email", form{}))
+	assert.False(t, hasError("email", nil))
+
+	tmpl := template.Must(template.New("form").Funcs(FormFuncs).Parse(`{{ if hasError "email" . }}<p>{{ fieldError "email" . }}</p>{{ end }}`))
+	buf := new(bytes.Buffer)
+	tmpl.Execute(buf, form{Errors: map[string][]string{"email": {"can't be <blank>"}}})
+	assert.Equal(t, buf.String(), "<p>can&#39;t be &lt;blank&gt;</p>")
+
+	buf.Reset()
+	tmpl.Execute(buf, form{})
+	assert.Equal(t, buf.String(), "")
+}
+
+func Test_AutoTable(t *testing.T) {
+	html, err := autoTable([]tableRow{{1, "<b>Bob</b>", "x"}, {2, "Alice", "y"}})
+	assert.Nil(t, err)
+	assert.Equal(t, html, template.HTML("<table><thead><tr><th>ID</th><th>Name</th></tr></thead><tbody>"+
+		"<tr><td>1</td><td>&lt;b&gt;Bob&lt;/b&gt;</td></tr><tr><td>2</td><td>Alice</td></tr></tbody></table>"))
+
+	html, err = autoTable([]*tableRow{{ID: 3}, nil})
+	assert.Nil(t, err)
+	assert.Contains(t, string(html), "<tr><td>3</td><td></td></tr><tr><td></td><td></td></tr>")
+
+	html, err = autoTable([]tableRow{})
+	assert.Nil(t, err)
+	assert.Equal(t, html, template.HTML(""))
+
+	_, err = autoTable([]string{"a"})
+	assert.NotNil(t, err)
+
+	_, err = autoTable(tableRow{})
+	assert.NotNil(t, err)
+}
+
+func Test_TimeAgo(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	assert.Equal(t, timeAgo(time.Time{}), "")
+	assert.Equal(t, timeAgo(now), "just now")
+	assert.Equal(t, timeAgo(now.Add(-30*time.Second)), "just now")
+	assert.Equal(t, timeAgo(now.Add(-time.Minute)), "1 minute ago")
+	assert.Equal(t, timeAgo(now.Add(-5*time.Minute)), "5 minutes ago")
+	assert.Equal(t, timeAgo(now.Add(-3*time.Hour)), "3 hours ago")
+	assert.Equal(t, timeAgo(now.Add(-49*time.Hour)), "2 days ago")
+	assert.Equal(t, timeAgo(now.AddDate(0, -2, 0)), "2 months ago")
+	assert.Equal(t, timeAgo(now.AddDate(-1, 0, 0)), "1 year ago")
+	assert.Equal(t, timeAgo(now.Add(3*time.Minute)), "in 3 minutes")
+	assert.Equal(t, timeAgo(now.Add(25*time.Hour)), "in 1 day")
+
+	// a fixed clock of the renderer
+	r := New(Options{Directory: "fixtures", Now: func() time.Time { return now.Add(2 * time.Hour) }})
+	tmpl := r.Copy()
+	tmpl.t.New("ago.html").Parse(`{{ timeAgo . }}`)
+
+	html, err := tmpl.HTML("ago", now)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "2 hours ago")
+}
+
+func Test_Highlight(t *testing.T) {
+	html, err := highlight("Go templates & <Go> tips", "go")
+	assert.Nil(t, err)
+	assert.Equal(t, html, template.HTML("<mark>Go</mark> templates &amp; &lt;<mark>Go</mark>&gt; tips"))
+
+	html, _ = highlight("searching", []string{"sear", "arch"})
+	assert.Equal(t, html, template.HTML("<mark>search</mark>ing"))
+
+	html, _ = highlight("ÉCOLE école", "école")
+	assert.Equal(t, html, template.HTML("<mark>ÉCOLE</mark> <mark>école</mark>"))
+
+	html, _ = highlight(`a "b" <c>`)
+	assert.Equal(t, html, template.HTML("a &#34;b&#34; &lt;c&gt;"))
+
+	html, _ = highlight("<b>", "", "b")
+	assert.Equal(t, html, template.HTML("&lt;<mark>b</mark>&gt;"))
+
+	_, err = highlight("text", 1)
+	assert.NotNil(t, err)
+}
+
+func Test_SortedKeys(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		keys, err := sortedKeys(map[string]int{"b": 2, "a": 1, "c": 3, "aa": 4})
+		assert.Nil(t, err)
+		assert.Equal(t, keys, []interface{}{"a", "aa", "b", "c"})
+
+		keys, err = sortedKeys(map[int]string{10: "x", -1: "y", 2: "z"})
+		assert.Nil(t, err)
+		assert.Equal(t, keys, []interface{}{-1, 2, 10})
+	}
+
+	_, err := sortedKeys([]string{"a"})
+	assert.NotNil(t, err)
+
+	_, err = sortedKeys(map[interface{}]int{"a": 1})
+	assert.NotNil(t, err)
+}
+
+func Test_SortedItems(t *testing.T) {
+	items, err := sortedItems(map[string]int{"b": 2, "a": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, items, []mapItem{{"a", 1}, {"b", 2}})
+
+	tmpl := template.Must(template.New("items").Funcs(DefaultFuncs).Parse(`{{ range sortedItems . }}{{ .Key }}={{ .Value }};{{ end }}`))
+	buf := new(bytes.Buffer)
+	tmpl.Execute(buf, map[int]string{3: "c", 1: "a", 2: "b"})
+	assert.Equal(t, buf.String(), "1=a;2=b;3=c;")
+}
@@ -0,0 +1,166 @@
+package wutrender
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// loadCatalogs reads "{Options.LocalesDirectory}/{locale}.json" (or an extension of Options.CatalogDecoders)
+// message catalogs of Options.Locales. Nested objects are flattened into dotted keys: "users.title".
+func (r *Renderer) loadCatalogs() (map[string]map[string]string, error) {
+	if len(r.options.Locales) == 0 {
+		return nil, nil
+	}
+
+	decoders := map[string]func(data []byte, v interface{}) error{".json": json.Unmarshal}
+	for ext, decode := range r.options.CatalogDecoders {
+		decoders[ext] = decode
+	}
+
+	exts := make([]string, 0, len(decoders))
+	for ext := range decoders {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	catalogs := map[string]map[string]string{}
+	for _, locale := range r.options.Locales {
+		messages := map[string]string{}
+
+		for _, ext := range exts {
+			data, file, err := r.readCatalog(locale + ext)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			var catalog map[string]interface{}
+			if err := decoders[ext](data, &catalog); err != nil {
+				return nil, fmt.Errorf("wutrender: catalog %s: %v", file, err)
+			}
+			flattenCatalog("", catalog, messages)
+		}
+
+		catalogs[locale] = messages
+	}
+
+	return catalogs, nil
+}
+
+// readCatalog reads name from Options.LocalesDirectory of Options.FS or the filesystem
+func (r *Renderer) readCatalog(name string) ([]byte, string, error) {
+	if r.options.FS != nil {
+		file := path.Join(filepath.ToSlash(r.options.LocalesDirectory), name)
+		data, err := fs.ReadFile(r.options.FS, file)
+
+		return data, file, err
+	}
+
+	file := filepath.Join(r.options.LocalesDirectory, name)
+	data, err := ioutil.ReadFile(file)
+
+	return data, file, err
+}
+
+// flattenCatalog stores messages of catalog into flat with dotted keys
+func flattenCatalog(prefix string, catalog map[string]interface{}, flat map[string]string) {
+	for key, v := range catalog {
+		switch msg := v.(type) {
+		case map[string]interface{}:
+			flattenCatalog(prefix+key+".", msg, flat)
+		case map[interface{}]interface{}:
+			// YAML decoders may produce interface keys
+			nested := make(map[string]interface{}, len(msg))
+			for k, v := range msg {
+				nested[fmt.Sprint(k)] = v
+			}
+			flattenCatalog(prefix+key+".", nested, flat)
+		default:
+			flat[prefix+key] = fmt.Sprint(msg)
+		}
+	}
+}
+
+// localeChain returns locale and its language ("de-AT", "de"), followed by the first of Options.Locales
+func (tmpl *TemplateCopy) localeChain() []string {
+	var chain []string
+	if tmpl.locale != "" {
+		chain = append(chain, tmpl.locale)
+		if i := strings.IndexByte(tmpl.locale, '-'); i > 0 {
+			chain = append(chain, tmpl.locale[:i])
+		}
+	}
+
+	if len(tmpl.options.Locales) > 0 && (len(chain) == 0 || chain[len(chain)-1] != tmpl.options.Locales[0]) {
+		chain = append(chain, tmpl.options.Locales[0])
+	}
+
+	return chain
+}
+
+// localize returns "sessions/new.html.de" full name of the localized variant of fullName, fullName if there is none
+func (tmpl *TemplateCopy) localize(fullName string) string {
+	if len(tmpl.options.Locales) == 0 {
+		return fullName
+	}
+
+	for _, locale := range tmpl.localeChain() {
+		if tmpl.exists(fullName + "." + locale) {
+			return fullName + "." + locale
+		}
+	}
+
+	return fullName
+}
+
+// translate returns the message of key in the copy locale formatted with args, the key itself if no catalog has it
+func (tmpl *TemplateCopy) translate(key string, args ...interface{}) string {
+	for _, locale := range tmpl.localeChain() {
+		msg, ok := tmpl.renderer.catalogs[locale][key]
+		if !ok {
+			continue
+		}
+
+		if len(args) > 0 {
+			return fmt.Sprintf(msg, args...)
+		}
+
+		return msg
+	}
+
+	return key
+}
+
+// Add t keyword - translates with the catalogs of Options.Locales: {{ t "users.greeting" .Name }}
+func addTranslate(tmpl *TemplateCopy) {
+	funcs := template.FuncMap{
+		"t": tmpl.translate,
+	}
+	tmpl.t.Funcs(funcs)
+	tmpl.text.Funcs(texttemplate.FuncMap(funcs))
+}
+
+// formatOf returns the format of a full template name: "html" of "users/show.html" and of its localized
+// "users/show.html.de" variant
+func (opt *Options) formatOf(fullName string) string {
+	ext := strings.TrimPrefix(filepath.Ext(fullName), ".")
+
+	for _, locale := range opt.Locales {
+		if ext == locale {
+			return strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(fullName, "."+ext)), ".")
+		}
+	}
+
+	return ext
+}
@@ -0,0 +1,79 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Translate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	templates := filepath.Join(dir, "templates")
+	locales := filepath.Join(dir, "locales")
+	os.Mkdir(templates, 0755)
+	os.Mkdir(locales, 0755)
+
+	ioutil.WriteFile(filepath.Join(locales, "en.json"), []byte(`{"hello": "Hello %s", "users": {"title": "Users"}, "only_en": "English"}`), 0644)
+	ioutil.WriteFile(filepath.Join(locales, "de.json"), []byte(`{"hello": "Hallo %s", "users": {"title": "Benutzer"}}`), 0644)
+	ioutil.WriteFile(filepath.Join(locales, "de.ini"), []byte("bye=Tschüss"), 0644)
+	ioutil.WriteFile(filepath.Join(templates, "page.html.tmpl"), []byte(`{{ t "hello" . }} {{ t "users.title" }} {{ t "only_en" }} {{ t "missing.key" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(templates, "intro.html.tmpl"), []byte(`intro {{ partial "card" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(templates, "intro.html.de.tmpl"), []byte(`Einleitung {{ partial "card" }} {{ t "bye" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(templates, "_card.html.tmpl"), []byte(`card`), 0644)
+	ioutil.WriteFile(filepath.Join(templates, "_card.html.de.tmpl"), []byte(`Karte`), 0644)
+	ioutil.WriteFile(filepath.Join(templates, "mail.txt.de.tmpl"), []byte(`<b>{{ . }}</b>`), 0644)
+
+	r := New(Options{
+		Directory:        templates,
+		Locales:          []string{"en", "de"},
+		LocalesDirectory: locales,
+		CatalogDecoders: map[string]func([]byte, interface{}) error{
+			".ini": func(data []byte, v interface{}) error {
+				catalog := map[string]interface{}{}
+				for _, line := range strings.Split(string(data), "\n") {
+					if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+						catalog[kv[0]] = kv[1]
+					}
+				}
+				*(v.(*map[string]interface{})) = catalog
+				return nil
+			},
+		},
+	})
+
+	html, err := r.Copy().HTML("page", "Ann")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "Hello Ann Users English missing.key")
+
+	html, err = r.Copy().SetLocale("de-AT").HTML("page", "Ann")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "Hallo Ann Benutzer English missing.key")
+
+	html, err = r.Copy().HTML("intro", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "intro card")
+
+	html, err = r.Copy().SetLocale("de").HTML("intro", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "Einleitung Karte Tschüss")
+
+	buf, err := r.Copy().SetLocale("de").RenderFormat("txt", "mail", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "<b>x</b>")
+
+	_, err = r.Copy().SetLocale("fr").RenderFormat("txt", "mail", "x")
+	assert.NotNil(t, err)
+
+	ioutil.WriteFile(filepath.Join(locales, "en.json"), []byte(`{"hello": `), 0644)
+	_, err = NewE(Options{
+		Directory:        templates,
+		Locales:          []string{"en"},
+		LocalesDirectory: locales,
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "en.json")
+}
@@ -0,0 +1,65 @@
+package wutrender
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// ErrInvalidCallback is wrapped by JSONP errors of callback names which aren't JavaScript identifiers (errors.Is)
+var ErrInvalidCallback = errors.New("wutrender: invalid JSONP callback")
+
+// jsonpCallback matches "callback", "jQuery123_456" and dotted "app.widgets.render" callback names
+var jsonpCallback = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// maxCallbackLength limits callback names, real ones are short
+const maxCallbackLength = 128
+
+// JSONP encodes v as the argument of a call of callback: /**/callback({"id":1});
+// The callback name comes from the request, so it must be a (dotted) JavaScript identifier.
+// Output is indented with Options.JSONIndent, <, > and & are always escaped and Options.JSONPrefix isn't added.
+func (tmpl *TemplateCopy) JSONP(callback string, v interface{}) (*bytes.Buffer, error) {
+	if len(callback) > maxCallbackLength || !jsonpCallback.MatchString(callback) {
+		return new(bytes.Buffer), fmt.Errorf("%w: %q", ErrInvalidCallback, callback)
+	}
+
+	data := new(bytes.Buffer)
+	enc := json.NewEncoder(data)
+	enc.SetIndent("", tmpl.options.JSONIndent)
+
+	if err := enc.Encode(v); err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	// the comment keeps content sniffing from taking the output for another file type
+	buf := bytes.NewBufferString("/**/" + callback + "(")
+	buf.Write(bytes.TrimSuffix(data.Bytes(), []byte("\n")))
+	buf.WriteString(");")
+
+	return buf, nil
+}
+
+// Write v as JSONP to ResponseWriter, responds with 400 for an invalid callback and the maintenance page
+// during maintenance
+func (tmpl *TemplateCopy) WriteJSONP(rw http.ResponseWriter, status int, callback string, v interface{}) {
+	if tmpl.writeMaintenance(rw) {
+		return
+	}
+
+	buf, err := tmpl.JSONP(callback, v)
+
+	if errors.Is(err, ErrInvalidCallback) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	tmpl.write(rw, status, ContentJS, buf)
+}
@@ -0,0 +1,39 @@
+package wutrender
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_JSONP(t *testing.T) {
+	r := New(Options{Directory: "fixtures", JSONUnescapeHTML: true, JSONPrefix: ")]}',\n"})
+	v := map[string]interface{}{"name": "</script><b>", "ids": []int{1, 2}}
+
+	buf, err := r.Copy().JSONP("jQuery123_456", v)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `/**/jQuery123_456({"ids":[1,2],"name":"\u003c/script\u003e\u003cb\u003e"});`)
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteJSONP(rw, 201, "app.widgets.$render", "\u2028")
+
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentJS)
+	assert.Equal(t, rw.Header().Get("X-Content-Type-Options"), "nosniff")
+	assert.Equal(t, rw.Body.String(), `/**/app.widgets.$render("\u2028");`)
+
+	for _, callback := range []string{"", "alert(1)//", "a.b.", "1abc", "a b", "a-b", "a[0]", "cb\n"} {
+		_, err = r.Copy().JSONP(callback, v)
+		assert.True(t, errors.Is(err, ErrInvalidCallback), callback)
+	}
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteJSONP(rw, 200, "alert(document.cookie)//", v)
+	assert.Equal(t, rw.Code, 400)
+	assert.NotContains(t, rw.Body.String(), "/**/")
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteJSONP(rw, 200, "cb", make(chan int))
+	assert.Equal(t, rw.Code, 500)
+}
@@ -0,0 +1,130 @@
+package wutrender
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// numberLocale describes number and currency formatting of a locale
+type numberLocale struct {
+	group   string
+	decimal string
+	symbol  string
+	// Symbol goes after the number: "1.234,56 €"
+	suffix bool
+}
+
+// Locales supported by the currency helper
+var numberLocales = map[string]numberLocale{
+	"en-US": {",", ".", "$", false},
+	"en-GB": {",", ".", "£", false},
+	"de-DE": {".", ",", "€", true},
+	"fr-FR": {" ", ",", "€", true},
+}
+
+// Locales of numberLocales used for other locales of the language, e.g. catalog locales "de" and "de-AT"
+var languageLocales = map[string]string{
+	"en": "en-US",
+	"de": "de-DE",
+	"fr": "fr-FR",
+}
+
+// numberLocaleOf returns the numberLocale of locale, or of its language
+func numberLocaleOf(locale string) (numberLocale, bool) {
+	if l, ok := numberLocales[locale]; ok {
+		return l, true
+	}
+
+	language := locale
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		language = locale[:i]
+	}
+
+	l, ok := numberLocales[languageLocales[language]]
+	return l, ok
+}
+
+// currencyFunc returns the currency helper for locale ("" is "en-US")
+func currencyFunc(locale string) func(v interface{}) (string, error) {
+	return func(v interface{}) (string, error) {
+		return currency(locale, v)
+	}
+}
+
+// currency formats a number with the locale currency symbol and separators: "$1,234.56", "1.234,56 €".
+// nil is ""
+func currency(locale string, v interface{}) (string, error) {
+	if locale == "" {
+		locale = "en-US"
+	}
+
+	l, ok := numberLocaleOf(locale)
+	if !ok {
+		return "", fmt.Errorf("wutrender: unsupported locale %q", locale)
+	}
+
+	if v == nil {
+		return "", nil
+	}
+
+	f, err := toFloat(v)
+	if err != nil {
+		return "", err
+	}
+
+	// -0.001 is "$0.00", not "-$0.00"
+	f = math.Round(f*100) / 100
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+	}
+
+	num := formatNumber(l, fmt.Sprintf("%.2f", math.Abs(f)))
+
+	if l.suffix {
+		return sign + num + " " + l.symbol, nil
+	}
+
+	return sign + l.symbol + num, nil
+}
+
+// formatNumber replaces separators of "1234.56" with the locale ones
+func formatNumber(l numberLocale, s string) string {
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	var groups []string
+	for len(intPart) > 3 {
+		groups = append([]string{intPart[len(intPart)-3:]}, groups...)
+		intPart = intPart[:len(intPart)-3]
+	}
+	groups = append([]string{intPart}, groups...)
+
+	s = strings.Join(groups, l.group)
+	if frac != "" {
+		s += l.decimal + frac
+	}
+
+	return s
+}
+
+// toFloat converts any number to float64
+func toFloat(v interface{}) (float64, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+
+	return 0, fmt.Errorf("wutrender: expected a number, got %T", v)
+}
@@ -0,0 +1,78 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_Currency(t *testing.T) {
+	s, err := currency("en-US", 1234.56)
+	assert.Nil(t, err)
+	assert.Equal(t, s, "$1,234.56")
+
+	s, _ = currency("de-DE", 1234.56)
+	assert.Equal(t, s, "1.234,56 €")
+
+	s, _ = currency("", -1234567)
+	assert.Equal(t, s, "-$1,234,567.00")
+
+	s, _ = currency("de-DE", -0.5)
+	assert.Equal(t, s, "-0,50 €")
+
+	s, _ = currency("en-GB", 0)
+	assert.Equal(t, s, "£0.00")
+
+	s, _ = currency("en-US", -0.001)
+	assert.Equal(t, s, "$0.00")
+
+	s, _ = currency("en-US", -0.005)
+	assert.Equal(t, s, "-$0.01")
+
+	s, err = currency("en-US", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, s, "")
+
+	_, err = currency("xx-XX", 1)
+	assert.NotNil(t, err)
+
+	// catalog locales use the locale of their language
+	s, err = currency("de", 1234.56)
+	assert.Nil(t, err)
+	assert.Equal(t, s, "1.234,56 €")
+
+	s, _ = currency("de-AT", 1)
+	assert.Equal(t, s, "1,00 €")
+
+	_, err = currency("xx", 1)
+	assert.NotNil(t, err)
+
+	_, err = currency("en-US", "1")
+	assert.NotNil(t, err)
+}
+
+func Test_SetLocale(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Locale:    "de-DE",
+	})
+
+	tmpl := r.Copy()
+	tmpl.t.New("price.html").Parse(`{{ currency . }}`)
+
+	html, err := tmpl.HTML("price", 999.9)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "999,90 €")
+
+	tmpl = r.Copy().SetLocale("en-US")
+	tmpl.t.New("price.html").Parse(`{{ currency . }}`)
+
+	html, _ = tmpl.HTML("price", 1999.9)
+	assert.Equal(t, html.String(), "$1,999.90")
+
+	tmpl = r.Copy().SetLocale("de")
+	tmpl.t.New("price.html").Parse(`{{ currency . }}`)
+
+	html, err = tmpl.HTML("price", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "5,00 €")
+}
@@ -0,0 +1,118 @@
+package wutrender
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// negotiatedFormat is a format Negotiate can pick and the media types it's served for
+type negotiatedFormat struct {
+	format      string
+	contentType string
+	mediaTypes  []string
+}
+
+// Formats picked by Negotiate, in order of preference for "*/*" and "type/*"
+var negotiatedFormats = []negotiatedFormat{
+	{"html", ContentHTML, []string{"text/html"}},
+	{"json", ContentJSON, []string{"application/json"}},
+	{"js", ContentJS, []string{"application/javascript", "text/javascript"}},
+	{"xml", ContentXML, []string{"application/xml", "text/xml"}},
+}
+
+// mediaRange is a media type of the Accept header with its quality
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// Negotiate renders the html, json, js or xml template of name which best matches the Accept header of r.
+// Requests without a matching Accept header (or template) get Options.NegotiateDefault.
+func (tmpl *TemplateCopy) Negotiate(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	f := tmpl.negotiate(r, name)
+
+	buf, err := tmpl.RenderFormat(f.format, name, binding)
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	rw.Header().Add("Vary", "Accept")
+	tmpl.write(rw, status, f.contentType, buf)
+}
+
+// negotiate picks the format of name for r
+func (tmpl *TemplateCopy) negotiate(r *http.Request, name string) negotiatedFormat {
+	def := negotiatedFormat{format: tmpl.options.NegotiateDefault, contentType: ContentHTML}
+
+	// default first for "*/*"
+	formats := []negotiatedFormat{}
+	for _, f := range negotiatedFormats {
+		if f.format == def.format {
+			def = f
+			formats = append([]negotiatedFormat{f}, formats...)
+		} else {
+			formats = append(formats, f)
+		}
+	}
+
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		for _, f := range formats {
+			if matchesMediaRange(f.mediaTypes, accepted.mediaType) && tmpl.exists(name+"."+f.format) {
+				return f
+			}
+		}
+	}
+
+	return def
+}
+
+// matchesMediaRange reports whether one of mediaTypes is in "type/subtype", "type/*" or "*/*" mediaRange
+func matchesMediaRange(mediaTypes []string, mediaRange string) bool {
+	for _, mediaType := range mediaTypes {
+		if mediaRange == "*/*" || mediaRange == mediaType {
+			return true
+		}
+		if strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseAccept returns media ranges of Accept header sorted by quality, skipping q=0
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = v
+			}
+		}
+
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType, q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	return ranges
+}
@@ -0,0 +1,60 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Negotiate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "user.html.tmpl"), []byte(`<b>{{ .Name }}</b>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "user.json.tmpl"), []byte(`{"name": "{{ .Name }}"}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "user.xml.tmpl"), []byte(`<user>{{ .Name }}</user>`), 0644)
+
+	r := New(Options{Directory: dir})
+	binding := map[string]string{"Name": "bob"}
+
+	for _, tt := range []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", ContentHTML, "<b>bob</b>"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", ContentHTML, "<b>bob</b>"},
+		{"application/json", ContentJSON, `{"name": "bob"}`},
+		{"application/xml;q=0.5, application/json;q=0.9", ContentJSON, `{"name": "bob"}`},
+		{"text/xml", ContentXML, "<user>bob</user>"},
+		{"application/*", ContentJSON, `{"name": "bob"}`},
+		{"*/*", ContentHTML, "<b>bob</b>"},
+		{"application/javascript", ContentHTML, "<b>bob</b>"},
+		{"application/json;q=0, text/xml", ContentXML, "<user>bob</user>"},
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		rw := httptest.NewRecorder()
+		r.Copy().Negotiate(rw, req, 200, "user", binding)
+
+		assert.Equal(t, rw.Code, 200, tt.accept)
+		assert.Equal(t, rw.Header().Get(ContentType), tt.contentType, tt.accept)
+		assert.Equal(t, rw.Header().Get("Vary"), "Accept", tt.accept)
+		assert.Equal(t, rw.Body.String(), tt.body, tt.accept)
+	}
+
+	r = New(Options{Directory: dir, NegotiateDefault: "json"})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	rw := httptest.NewRecorder()
+	r.Copy().Negotiate(rw, req, 200, "user", binding)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentJSON)
+
+	req.Header.Set("Accept", "*/*")
+	rw = httptest.NewRecorder()
+	r.Copy().Negotiate(rw, req, 200, "user", binding)
+	assert.Equal(t, rw.Body.String(), `{"name": "bob"}`)
+}
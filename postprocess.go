@@ -0,0 +1,253 @@
+package wutrender
+
+import (
+	"bytes"
+	"go/format"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// postProcess transforms rendered output according to the options
+func (tmpl *TemplateCopy) postProcess(format string, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	var err error
+
+	if format == "go" && tmpl.options.FormatGo {
+		buf, err = formatGo(buf)
+		if err != nil {
+			return buf, err
+		}
+	}
+
+	if format == "xml" {
+		buf = xmlDeclaration(buf)
+	}
+
+	if format == "html" && tmpl.options.AbsoluteBaseURL != "" {
+		buf, err = absoluteURLs(buf, tmpl.options.AbsoluteBaseURL)
+		if err != nil {
+			return buf, err
+		}
+	}
+
+	if format == "html" && tmpl.options.HeadingAnchors {
+		buf = bytes.NewBuffer(HeadingAnchors(buf.Bytes()))
+	}
+
+	if format == "html" && tmpl.options.NormalizeHTML {
+		buf = bytes.NewBuffer(NormalizeHTML(buf.Bytes()))
+	}
+
+	if format == "html" && tmpl.csrf != "" {
+		meta := `<meta name="csrf-token" content="` + html.EscapeString(tmpl.csrf) + `">`
+		buf = bytes.NewBuffer(injectHead(buf.Bytes(), meta))
+	}
+
+	if minify, ok := tmpl.options.Minifiers[format]; ok {
+		b, err := minify(buf.Bytes())
+		if err != nil {
+			return new(bytes.Buffer), err
+		}
+		buf = bytes.NewBuffer(b)
+	}
+
+	if ensure := tmpl.options.EnsureTrailingNewline; ensure != nil {
+		b := bytes.TrimRight(buf.Bytes(), "\r\n")
+		if *ensure {
+			b = append(b, '\n')
+		}
+		buf = bytes.NewBuffer(b)
+	}
+
+	return buf, nil
+}
+
+// xmlDeclaration restores the <?xml ...?> declaration of xml output escaped by html/template
+func xmlDeclaration(buf *bytes.Buffer) *bytes.Buffer {
+	b := buf.Bytes()
+	start := len(b) - len(bytes.TrimLeft(b, " \t\r\n"))
+
+	if bytes.HasPrefix(b[start:], []byte("&lt;?xml")) {
+		b = append(b[:start], append([]byte("<"), b[start+len("&lt;"):]...)...)
+		return bytes.NewBuffer(b)
+	}
+
+	return buf
+}
+
+// injectHead inserts snippet right after the <head> tag, html without <head> is returned as is
+func injectHead(b []byte, snippet string) []byte {
+	z := html.NewTokenizer(bytes.NewReader(b))
+	offset := 0
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return b
+		}
+
+		offset += len(z.Raw())
+
+		if name, _ := z.TagName(); tt == html.StartTagToken && string(name) == "head" {
+			out := make([]byte, 0, len(b)+len(snippet))
+			out = append(out, b[:offset]...)
+			out = append(out, snippet...)
+			return append(out, b[offset:]...)
+		}
+	}
+}
+
+// addPreamble prepends preamble to html which doesn't start with a doctype
+func addPreamble(buf *bytes.Buffer, preamble string) *bytes.Buffer {
+	if preamble == "" {
+		return buf
+	}
+
+	start := bytes.TrimLeft(buf.Bytes(), " \t\r\n")
+	if len(start) >= 9 && strings.EqualFold(string(start[:9]), "<!doctype") {
+		return buf
+	}
+
+	return bytes.NewBuffer(append([]byte(preamble), buf.Bytes()...))
+}
+
+// Elements with significant whitespace
+var preserveWhitespace = map[string]bool{"pre": true, "textarea": true, "script": true, "style": true}
+
+// NormalizeHTML collapses whitespace runs of text to a single space and drops whitespace
+// with line breaks at the edges of text (indentation between tags).
+// Content of <pre>, <textarea>, <script> and <style> is kept as is.
+// Useful for comparing rendered HTML in golden-file tests.
+func NormalizeHTML(b []byte) []byte {
+	out := new(bytes.Buffer)
+	preserve := 0
+
+	z := html.NewTokenizer(bytes.NewReader(b))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.Bytes()
+		}
+
+		raw := z.Raw()
+		name, _ := z.TagName()
+
+		switch {
+		case tt == html.StartTagToken && preserveWhitespace[string(name)]:
+			preserve++
+		case tt == html.EndTagToken && preserveWhitespace[string(name)] && preserve > 0:
+			preserve--
+		case tt == html.TextToken && preserve == 0:
+			raw = collapseWhitespace(raw)
+		}
+
+		out.Write(raw)
+	}
+}
+
+// collapseWhitespace replaces whitespace runs with a space, runs with line breaks at the edges are dropped
+func collapseWhitespace(text []byte) []byte {
+	out := make([]byte, 0, len(text))
+
+	for i := 0; i < len(text); {
+		if !isSpace(text[i]) {
+			out = append(out, text[i])
+			i++
+			continue
+		}
+
+		start, newline := i, false
+		for ; i < len(text) && isSpace(text[i]); i++ {
+			newline = newline || text[i] == '\n'
+		}
+
+		if !newline || (start > 0 && i < len(text)) {
+			out = append(out, ' ')
+		}
+	}
+
+	return out
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// formatGo runs rendered Go code through gofmt, which also catches syntax errors
+func formatGo(buf *bytes.Buffer) (*bytes.Buffer, error) {
+	src, err := format.Source(buf.Bytes())
+
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	return bytes.NewBuffer(src), nil
+}
+
+// absoluteURLs resolves relative href and src attributes against baseURL.
+// Absolute, protocol-relative, data: and "#fragment" URLs are left as is.
+func absoluteURLs(buf *bytes.Buffer, baseURL string) (*bytes.Buffer, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	out := new(bytes.Buffer)
+	z := html.NewTokenizer(buf)
+
+	for {
+		tt := z.Next()
+
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				return out, nil
+			}
+			return new(bytes.Buffer), z.Err()
+		}
+
+		raw := z.Raw()
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		// copy raw bytes since Token() may reuse the buffer
+		raw = append([]byte(nil), raw...)
+		token := z.Token()
+		changed := false
+
+		for i, attr := range token.Attr {
+			if attr.Namespace != "" || (attr.Key != "href" && attr.Key != "src") {
+				continue
+			}
+
+			if abs, ok := absoluteURL(base, attr.Val); ok {
+				token.Attr[i].Val = abs
+				changed = true
+			}
+		}
+
+		if changed {
+			out.WriteString(token.String())
+		} else {
+			out.Write(raw)
+		}
+	}
+}
+
+// absoluteURL resolves relative rawURL against base, ok is false if rawURL should be left as is
+func absoluteURL(base *url.URL, rawURL string) (string, bool) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" || strings.HasPrefix(rawURL, "#") {
+		return "", false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.IsAbs() || u.Host != "" {
+		return "", false
+	}
+
+	return base.ResolveReference(u).String(), true
+}
@@ -0,0 +1,180 @@
+package wutrender
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_AbsoluteURLs(t *testing.T) {
+	in := `<p>Hi &amp; welcome</p>` +
+		`<a href="/orders/1?x=1&amp;y=2">order</a>` +
+		`<a href="help">help</a>` +
+		`<a href="https://example.org/x">abs</a>` +
+		`<a href="//cdn.example.org/x">proto</a>` +
+		`<a href="#top">top</a>` +
+		`<a href="mailto:a@b.c">mail</a>` +
+		`<img src="data:image/png;base64,AAAA">` +
+		`<img src="img/logo.png" alt="Logo"/>`
+
+	out, err := absoluteURLs(bytes.NewBufferString(in), "https://shop.example.com/app/")
+	assert.Nil(t, err)
+	assert.Equal(t, out.String(), `<p>Hi &amp; welcome</p>`+
+		`<a href="https://shop.example.com/orders/1?x=1&amp;y=2">order</a>`+
+		`<a href="https://shop.example.com/app/help">help</a>`+
+		`<a href="https://example.org/x">abs</a>`+
+		`<a href="//cdn.example.org/x">proto</a>`+
+		`<a href="#top">top</a>`+
+		`<a href="mailto:a@b.c">mail</a>`+
+		`<img src="data:image/png;base64,AAAA">`+
+		`<img src="https://shop.example.com/app/img/logo.png" alt="Logo"/>`)
+}
+
+func Test_AbsoluteBaseURL(t *testing.T) {
+	r := New(Options{
+		Directory:       "fixtures",
+		AbsoluteBaseURL: "https://example.com",
+	})
+
+	tmpl := r.Copy()
+	tmpl.t.New("email.html").Parse(`<a href="{{ . }}">link</a>`)
+
+	html, err := tmpl.HTML("email", "/confirm")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<a href="https://example.com/confirm">link</a>`)
+}
+
+func Test_NormalizeHTML(t *testing.T) {
+	a := []byte(`<ul>
+  <li>One  item</li>
+  <li>
+    Two
+  </li>
+</ul>
+<p>Hello <b>big</b> world</p>`)
+	b := []byte("<ul><li>One item</li><li>Two</li></ul><p>Hello   <b>big</b>\tworld</p>")
+
+	assert.Equal(t, string(NormalizeHTML(a)), string(NormalizeHTML(b)))
+	assert.Equal(t, string(NormalizeHTML(a)), "<ul><li>One item</li><li>Two</li></ul><p>Hello <b>big</b> world</p>")
+
+	pre := []byte("<div>\n  <pre>  line 1\n    line 2\n</pre>\n  <textarea>\n a  b\n</textarea>\n</div>")
+	assert.Equal(t, string(NormalizeHTML(pre)), "<div><pre>  line 1\n    line 2\n</pre><textarea>\n a  b\n</textarea></div>")
+}
+
+func Test_NormalizeHTMLOption(t *testing.T) {
+	r := New(Options{
+		Directory:     "fixtures",
+		NormalizeHTML: true,
+	})
+
+	html, err := r.Copy().HTML("email/welcome", map[string]string{"Name": "x"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<p>Hi x, welcome!</p><p>Thanks</p>")
+}
+
+func Test_Minifiers(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+		Minifiers: map[string]func([]byte) ([]byte, error){
+			"html": func(b []byte) ([]byte, error) {
+				return bytes.Replace(b, []byte("\n"), nil, -1), nil
+			},
+			"js": func(b []byte) ([]byte, error) {
+				return append([]byte("/*min*/"), b...), nil
+			},
+		},
+	})
+
+	html, err := r.Copy().HTML("base/hello", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head<div>Hello x</div>foot")
+
+	js, err := r.Copy().JS("minify/app", "hi")
+	assert.Nil(t, err)
+	assert.Equal(t, js.String(), `/*min*/alert("hi");`)
+
+	// formats without a minifier are unchanged
+	css, err := r.Copy().RenderFormat("css", "critical/home", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, css.String(), "body { margin: 0; }")
+
+	r.options.Minifiers["js"] = func(b []byte) ([]byte, error) {
+		return nil, errors.New("minify: unexpected token")
+	}
+
+	_, err = r.Copy().JS("minify/app", "hi")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected token")
+}
+
+func Test_HTMLPreamble(t *testing.T) {
+	r := New(Options{
+		Directory:    "fixtures",
+		HTMLPreamble: "<!DOCTYPE html>\n",
+	})
+
+	html, err := r.Copy().HTML("base/hello", "fragment")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<!DOCTYPE html>\n<div>Hello fragment</div>")
+
+	// the layout provides the doctype
+	html, err = r.Copy().SetLayout("base/layout").HTML("base/hello", "page")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head\n<div>Hello page</div>\nfoot")
+
+	js, err := r.Copy().JS("minify/app", "hi")
+	assert.Nil(t, err)
+	assert.Equal(t, js.String(), `alert("hi");`)
+
+	assert.Equal(t, addPreamble(bytes.NewBufferString("\n<!doctype html><p>"), "<!DOCTYPE html>").String(), "\n<!doctype html><p>")
+}
+
+func Test_SetCSRF(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "critical/layout",
+	})
+
+	html, err := r.Copy().SetCSRF(`t0k"en`).HTML("base/hello", "a")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<head><meta name="csrf-token" content="t0k&#34;en"><style>body { margin: 0; }</style></head>`+"\n<div>Hello a</div>")
+
+	html, _ = r.Copy().SetCSRF("other").HTML("base/hello", "b")
+	assert.Contains(t, html.String(), `<meta name="csrf-token" content="other">`)
+
+	// no <head>
+	html, err = r.Copy().SetLayout("").SetCSRF("other").HTML("base/hello", "c")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<div>Hello c</div>")
+
+	assert.Equal(t, string(injectHead([]byte("<header>x</header><HEAD lang=en></HEAD>"), "<meta>")), "<header>x</header><HEAD lang=en><meta></HEAD>")
+}
+
+func Test_EnsureTrailingNewline(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "list.txt.tmpl"), []byte("{{ range . }}{{ . }}\n{{ end }}\n\r\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "inline.txt.tmpl"), []byte("{{ . }}"), 0644)
+
+	render := func(ensure *bool, name string) string {
+		r := New(Options{
+			Directory:             dir,
+			EnsureTrailingNewline: ensure,
+		})
+		buf, err := r.Copy().RenderFormat("txt", name, []string{"a", "b"})
+		assert.Nil(t, err)
+		return buf.String()
+	}
+	on, off := true, false
+
+	assert.Equal(t, render(nil, "list"), "a\nb\n\n\r\n")
+	assert.Equal(t, render(&on, "list"), "a\nb\n")
+	assert.Equal(t, render(&on, "inline"), "[a b]\n")
+	assert.Equal(t, render(&off, "list"), "a\nb")
+	assert.Equal(t, render(&off, "inline"), "[a b]")
+}
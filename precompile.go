@@ -0,0 +1,72 @@
+package wutrender
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"html/template"
+	"io/ioutil"
+	"strconv"
+)
+
+// Source is a template file loaded by the Renderer: "sessions/new.html" name and its content
+type Source struct {
+	Name string
+	Text string
+
+	// File path for errors, "" for embedded sources
+	path string
+}
+
+// NewFromSources creates a Renderer from embedded templates instead of walking Options.Directory,
+// the sources are parsed as New parses files. It is used by the NewPrecompiled constructor emitted by GenerateGo.
+func NewFromSources(sources []Source, opt ...Options) *Renderer {
+	r := &Renderer{
+		options: prepareOptions(opt),
+		css:     map[string]template.CSS{},
+		sources: sources,
+	}
+
+	if err := r.init(); err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// GenerateGo writes a Go file of package pkg to out, embedding all template files as string literals.
+// The generated NewPrecompiled(opt ...wutrender.Options) constructor skips reading the filesystem on startup,
+// but still parses the embedded sources: parse trees can't be written as Go source, their nodes keep
+// unexported state html/template and text/template need to execute them.
+func (r *Renderer) GenerateGo(pkg, out string) error {
+	sources, err := r.loadSources()
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "// Code generated by wutrender.GenerateGo. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	fmt.Fprintf(buf, "import \"github.com/8protons/wutrender\"\n\n")
+	fmt.Fprintf(buf, "var precompiledSources = []wutrender.Source{\n")
+	for _, src := range sources {
+		// embedded sources are always parsed with html/template or text/template
+		if r.sourceEngine(src) != nil {
+			continue
+		}
+		fmt.Fprintf(buf, "\t{Name: %s, Text: %s},\n", strconv.Quote(src.Name), strconv.Quote(src.Text))
+	}
+	fmt.Fprintf(buf, "}\n\n")
+	fmt.Fprintf(buf, "// NewPrecompiled creates a Renderer from the embedded templates\n")
+	fmt.Fprintf(buf, "func NewPrecompiled(opt ...wutrender.Options) *wutrender.Renderer {\n")
+	fmt.Fprintf(buf, "\treturn wutrender.NewFromSources(precompiledSources, opt...)\n")
+	fmt.Fprintf(buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(out, src, 0644)
+}
@@ -0,0 +1,91 @@
+package wutrender
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func Test_GenerateGo(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "templates.go")
+
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+	assert.Nil(t, r.GenerateGo("views", out))
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, out, nil, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, file.Name.Name, "views")
+	assert.NotNil(t, file.Scope.Lookup("NewPrecompiled"))
+
+	// read embedded sources back from the generated literals
+	var sources []Source
+	ast.Inspect(file, func(node ast.Node) bool {
+		lit, ok := node.(*ast.CompositeLit)
+		if !ok || len(lit.Elts) != 2 {
+			return true
+		}
+
+		var src Source
+		for _, elt := range lit.Elts {
+			kv := elt.(*ast.KeyValueExpr)
+			value, _ := strconv.Unquote(kv.Value.(*ast.BasicLit).Value)
+			if kv.Key.(*ast.Ident).Name == "Name" {
+				src.Name = value
+			} else {
+				src.Text = value
+			}
+		}
+		sources = append(sources, src)
+
+		return false
+	})
+
+	p := NewFromSources(sources, Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	assert.Equal(t, templateNames(p), templateNames(r))
+	assert.Equal(t, p.UserTemplateCount(), r.UserTemplateCount())
+
+	for _, name := range []string{"base/hello", "raw/page", "isolated/page"} {
+		expected, _ := r.Copy().HTML(name, map[string]interface{}{"Name": "<b>bob</b>"})
+		html, err := p.Copy().HTML(name, map[string]interface{}{"Name": "<b>bob</b>"})
+		assert.Nil(t, err)
+		assert.Equal(t, html.String(), expected.String())
+	}
+
+	binding := map[string]interface{}{"Package": "models", "Name": "User"}
+	expected, _ := r.Copy().RenderFormat("go", "gen/model", binding)
+	buf, err := p.Copy().RenderFormat("go", "gen/model", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), expected.String())
+}
+
+func templateNames(r *Renderer) []string {
+	var names []string
+	t, text := r.templates()
+
+	for _, tmpl := range t.Templates() {
+		names = append(names, tmpl.Name())
+	}
+	for _, tmpl := range text.Templates() {
+		names = append(names, "text:"+tmpl.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
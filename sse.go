@@ -0,0 +1,93 @@
+package wutrender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ContentEventStream is the content type of Server-Sent Events
+const ContentEventStream = "text/event-stream"
+
+// SSEEvent sets the event name and id of an event sent by WriteSSE, e.g. for hx-sse swaps: sse-swap="message"
+type SSEEvent struct {
+	Event string
+	ID    string
+	// Binding of the template
+	Binding interface{}
+}
+
+// WriteSSE renders the html template name without layout for every binding received from bindings
+// and sends it as a Server-Sent Event, flushing after every event. Bindings may be SSEEvent to name events.
+// Returns nil once bindings is closed, the error of r.Context() when the client went away
+// or the first render error.
+func (tmpl *TemplateCopy) WriteSSE(rw http.ResponseWriter, r *http.Request, name string, bindings <-chan interface{}) error {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		return errors.New("wutrender: WriteSSE needs a ResponseWriter implementing http.Flusher")
+	}
+
+	rw.Header().Set(ContentType, ContentEventStream)
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// events are fragments: no layout and no Options.HTMLPreamble, the layout of the copy is kept
+	ctx := context.WithValue(r.Context(), layoutOverrideKey{}, layoutOverride{fragment: true})
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case binding, ok := <-bindings:
+			if !ok {
+				return nil
+			}
+
+			var event SSEEvent
+			if e, ok := binding.(SSEEvent); ok {
+				event = e
+			} else {
+				event.Binding = binding
+			}
+
+			html, err := tmpl.RenderFormatContext(ctx, "html", name, event.Binding)
+			if err != nil {
+				return err
+			}
+
+			_, err = rw.Write(sseFrame(event, html))
+			ReleaseBuffer(html)
+			if err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// sseFrame returns the event stream framing of event with data, every line of data is a "data:" field
+func sseFrame(event SSEEvent, data *bytes.Buffer) []byte {
+	var frame bytes.Buffer
+
+	if event.Event != "" {
+		frame.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+	if event.ID != "" {
+		frame.WriteString("id: " + sseField(event.ID) + "\n")
+	}
+
+	text := strings.TrimSuffix(strings.ReplaceAll(data.String(), "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		frame.WriteString("data: " + line + "\n")
+	}
+	frame.WriteString("\n")
+
+	return frame.Bytes()
+}
+
+// sseField removes line breaks which would end an event or id field
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
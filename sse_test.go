@@ -0,0 +1,61 @@
+package wutrender
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WriteSSE(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "item.html.tmpl"), []byte("<li>{{ . }}</li>\n<li>end</li>\n"), 0644)
+
+	r := New(Options{Directory: dir, Layout: "layout", HTMLPreamble: "<!DOCTYPE html>\n"})
+
+	bindings := make(chan interface{}, 2)
+	bindings <- "a"
+	bindings <- SSEEvent{Event: "update", ID: "2\n", Binding: "<b>"}
+	close(bindings)
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/events", nil)
+	tmpl := r.Copy()
+	err := tmpl.WriteSSE(rw, req, "item", bindings)
+
+	assert.Nil(t, err)
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentEventStream)
+	assert.Equal(t, rw.Header().Get("Cache-Control"), "no-cache")
+	assert.True(t, rw.Flushed)
+	assert.Equal(t, rw.Body.String(), "data: <li>a</li>\ndata: <li>end</li>\n\n"+
+		"event: update\nid: 2\ndata: <li>&lt;b&gt;</li>\ndata: <li>end</li>\n\n")
+
+	// the copy keeps its layout
+	html, err := tmpl.HTML("item", "c")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><li>c</li>\n<li>end</li>\n</main>")
+
+	// client went away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.Copy().WriteSSE(httptest.NewRecorder(), req.WithContext(ctx), "item", make(chan interface{}))
+	assert.Equal(t, err, context.Canceled)
+
+	// render errors stop the stream
+	bindings = make(chan interface{}, 1)
+	bindings <- "a"
+	err = r.Copy().WriteSSE(httptest.NewRecorder(), req, "missing", bindings)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+
+	// no http.Flusher
+	err = r.Copy().WriteSSE(struct{ http.ResponseWriter }{httptest.NewRecorder()}, req, "item", bindings)
+	assert.NotNil(t, err)
+}
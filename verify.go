@@ -0,0 +1,151 @@
+package wutrender
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// verifiedHelpers are the helpers Verify checks, with the position of the template name in their args
+var verifiedHelpers = map[string]int{
+	"partial":         1,
+	"partialIsolated": 1,
+	"partialRaw":      1,
+	"cachedPartial":   3,
+	"render":          1,
+	"macro":           1,
+	"extends":         1,
+}
+
+// Verify checks that every static partial, macro, extends and template reference points at a loaded template.
+// References with non-literal names can't be checked and are logged as warnings.
+func (r *Renderer) Verify() error {
+	var missing []string
+	t, text := r.templates()
+
+	// "users/_card.html" of "users/_card.html.de", partials are localized like this at runtime
+	localized := map[string]bool{}
+	for _, tmpl := range t.Templates() {
+		if i := strings.LastIndexByte(tmpl.Name(), '.'); i > 0 {
+			localized[tmpl.Name()[:i]] = true
+		}
+	}
+
+	check := func(tree *parse.Tree) {
+		walkTree(tree.Root, func(node parse.Node) {
+			switch n := node.(type) {
+			case *parse.TemplateNode:
+				if t.Lookup(n.Name) == nil && text.Lookup(n.Name) == nil {
+					missing = append(missing, fmt.Sprintf("%s: template %q", tree.Name, n.Name))
+				}
+			case *parse.CommandNode:
+				ident, ok := n.Args[0].(*parse.IdentifierNode)
+				if !ok {
+					return
+				}
+				arg, ok := verifiedHelpers[ident.Ident]
+				if !ok {
+					return
+				}
+
+				var name *parse.StringNode
+				if len(n.Args) > arg {
+					name, _ = n.Args[arg].(*parse.StringNode)
+				}
+				if name == nil {
+					r.warnf("%s: %s with non-literal name can't be verified: %s", tree.Name, ident.Ident, n)
+					return
+				}
+
+				found := false
+				dir, filename := filepath.Split(name.Text)
+				switch ident.Ident {
+				case "extends":
+					found = t.Lookup(name.Text+".html") != nil
+				case "macro":
+					found = t.Lookup(name.Text) != nil
+				case "partialRaw":
+					// "_{filename}.txt" unless the name has the extension of another text format
+					fullName := dir + "_" + filename
+					if format := strings.TrimPrefix(filepath.Ext(filename), "."); format == "" || !r.options.isTextFormat(format) {
+						fullName += ".txt"
+					}
+					found = text.Lookup(fullName) != nil
+				default:
+					fullName := dir + "_" + filename + ".html"
+					found = t.Lookup(fullName) != nil || localized[fullName]
+				}
+
+				if !found {
+					missing = append(missing, fmt.Sprintf("%s: %s %q", tree.Name, ident.Ident, name.Text))
+				}
+			}
+		})
+	}
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			check(tmpl.Tree)
+		}
+	}
+	for _, tmpl := range text.Templates() {
+		if tmpl.Tree != nil {
+			check(tmpl.Tree)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("wutrender: missing templates:\n\t%s", strings.Join(missing, "\n\t"))
+	}
+
+	return nil
+}
+
+// walkTree calls fn for node and all nodes below it
+func walkTree(node parse.Node, fn func(parse.Node)) {
+	if node == nil {
+		return
+	}
+
+	fn(node)
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkTree(c, fn)
+		}
+	case *parse.ActionNode:
+		walkTree(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walkTree(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, c := range n.Args {
+			walkTree(c, fn)
+		}
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		walkTree(n.Pipe, fn)
+	}
+}
+
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	walkTree(n.Pipe, fn)
+	walkTree(n.List, fn)
+	walkTree(n.ElseList, fn)
+}
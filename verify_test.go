@@ -0,0 +1,92 @@
+package wutrender
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Verify(t *testing.T) {
+	warnings := new(bytes.Buffer)
+
+	r := New(Options{
+		Directory: "fixtures",
+		Logger:    log.New(warnings, "", 0),
+	})
+
+	err := r.Verify()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `dangling/page.html: partial "dangling/missing"`)
+	assert.NotContains(t, err.Error(), "isolated/page.html")
+	assert.Contains(t, warnings.String(), "dangling/dynamic.html: partial with non-literal name")
+}
+
+func Test_VerifyValid(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ partial "users/user" }}{{ template "footer.html" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "footer.html.tmpl"), []byte(`footer`), 0644)
+	os.Mkdir(filepath.Join(dir, "users"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "users", "_user.html.tmpl"), []byte(`{{ range . }}{{ partialIsolated "users/user" }}{{ end }}`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+	})
+
+	assert.Nil(t, r.Verify())
+}
+
+func Test_VerifyHelpers(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "raw.html.tmpl"), []byte(`{{ partialRaw "plain" }}{{ partialRaw "style.css" }}{{ partialRaw "gone" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "cached.html.tmpl"), []byte(`{{ cachedPartial "nav" "5m" "nav" }}{{ cachedPartial "menu" "5m" "menu" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "macros.html.tmpl"), []byte(`{{ define "button" }}<button>{{ .Text }}</button>{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "form.html.tmpl"), []byte(`{{ macro "button" "Save" }}{{ macro "link" "Back" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_plain.txt.tmpl"), []byte(`plain`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_style.css.tmpl"), []byte(`b {}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_nav.html.tmpl"), []byte(`nav`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Macros:    map[string][]string{"button": {"Text"}, "link": {"Text"}},
+	})
+
+	err := r.Verify()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `raw.html: partialRaw "gone"`)
+	assert.NotContains(t, err.Error(), `"plain"`)
+	assert.NotContains(t, err.Error(), `"style.css"`)
+	assert.Contains(t, err.Error(), `cached.html: cachedPartial "menu"`)
+	assert.NotContains(t, err.Error(), `"nav"`)
+	assert.Contains(t, err.Error(), `form.html: macro "link"`)
+	assert.NotContains(t, err.Error(), `"button"`)
+}
+
+func Test_VerifyLocalized(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ partial "card" }}{{ cachedPartial "nav" "5m" "nav" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "other.html.tmpl"), []byte(`{{ partial "gone" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.de.tmpl"), []byte(`Karte`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_nav.html.de-AT.tmpl"), []byte(`Navigation`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Locales:   []string{"de"},
+	})
+
+	html, err := r.Copy().SetLocale("de-AT").HTML("page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "KarteNavigation")
+
+	err = r.Verify()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `other.html: partial "gone"`)
+	assert.NotContains(t, err.Error(), `"card"`)
+	assert.NotContains(t, err.Error(), `"nav"`)
+}
@@ -0,0 +1,360 @@
+package wutrender
+
+import (
+	"context"
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
+)
+
+// watchedSource is a parsed template file of Watch
+type watchedSource struct {
+	// Template name and index of its directory in Options.Directories
+	name string
+	dir  int
+	// Parsed with text/template
+	text bool
+	// The file template and its {{ define }} templates by name
+	trees map[string]*parse.Tree
+}
+
+// Watch watches Options.Directory (or Options.Directories) for changes and reparses only the changed template files,
+// so copies are cloned instead of recompiling all templates in development.
+// Changes are applied once no changes were seen for Options.ReloadDebounce,
+// on parse errors the old templates are kept. Watching stops with ctx or StopWatching.
+func (r *Renderer) Watch(ctx context.Context) error {
+	if r.sources != nil || r.options.FS != nil {
+		return errors.New("wutrender: Watch needs templates on disk, embedded templates and Options.FS can't be watched")
+	}
+
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+
+	if r.watcher != nil {
+		return errors.New("wutrender: already watching")
+	}
+
+	sources, err := r.loadSources()
+	if err != nil {
+		return err
+	}
+
+	watched := map[string]*watchedSource{}
+	for _, src := range sources {
+		// engines keep the templates compiled by New and Reload
+		if r.sourceEngine(src) != nil {
+			continue
+		}
+
+		if watched[src.path], err = r.parseSource(src); err != nil {
+			return err
+		}
+		watched[src.path].dir, _ = r.directoryOf(src.path)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range r.directories() {
+		if err := watchDirs(watcher, dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	r.watched = watched
+	if err := r.assemble(); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.watcher = watcher
+	r.stopWatching = cancel
+	r.changed = map[string]bool{}
+
+	go r.watch(ctx, watcher)
+
+	return nil
+}
+
+// StopWatching stops Watch, copies are recompiled again in development
+func (r *Renderer) StopWatching() {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+
+	r.stop(r.watcher)
+}
+
+// stop closes watcher if it's the current one, callers hold watchMu
+func (r *Renderer) stop(watcher *fsnotify.Watcher) {
+	if watcher == nil || r.watcher != watcher {
+		return
+	}
+
+	r.stopWatching()
+	watcher.Close()
+
+	if r.changeTimer != nil {
+		r.changeTimer.Stop()
+	}
+
+	r.watcher = nil
+	r.watched = nil
+}
+
+// isWatching reports whether Watch keeps the templates up to date
+func (r *Renderer) isWatching() bool {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+
+	return r.watcher != nil
+}
+
+// watch handles watcher events until ctx is done
+func (r *Renderer) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case <-ctx.Done():
+			r.watchMu.Lock()
+			r.stop(watcher)
+			r.watchMu.Unlock()
+			return
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			r.handleEvent(watcher, ev)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			r.warnf("watch: %v", err)
+		}
+	}
+}
+
+// handleEvent records changed template files and schedules their reload
+func (r *Renderer) handleEvent(watcher *fsnotify.Watcher, ev fsnotify.Event) {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+
+	if r.watcher != watcher {
+		return
+	}
+
+	if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+		if !ev.Has(fsnotify.Create) {
+			return
+		}
+
+		// a new directory may already have files
+		if err := watchDirs(watcher, ev.Name); err != nil {
+			r.warnf("watch: %v", err)
+		}
+		filepath.Walk(ev.Name, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && r.isTemplateFile(path) {
+				r.changed[path] = true
+			}
+			return nil
+		})
+	} else if r.isTemplateFile(ev.Name) {
+		r.changed[ev.Name] = true
+	} else if ev.Has(fsnotify.Remove) || ev.Has(fsnotify.Rename) {
+		// a removed directory
+		for path := range r.watched {
+			if strings.HasPrefix(path, ev.Name+string(filepath.Separator)) {
+				r.changed[path] = true
+			}
+		}
+	}
+
+	if len(r.changed) == 0 {
+		return
+	}
+
+	if r.changeTimer != nil {
+		r.changeTimer.Stop()
+	}
+
+	r.changeTimer = time.AfterFunc(r.options.ReloadDebounce, func() {
+		r.watchMu.Lock()
+		defer r.watchMu.Unlock()
+
+		if r.watcher == watcher {
+			r.reloadChanged()
+		}
+	})
+}
+
+// reloadChanged reparses changed files and assembles the templates, callers hold watchMu
+func (r *Renderer) reloadChanged() {
+	changed := r.changed
+	r.changed = map[string]bool{}
+
+	for path := range changed {
+		buf, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			delete(r.watched, path)
+			continue
+		}
+		if err != nil {
+			r.warnf("reload failed: %v", err)
+			continue
+		}
+
+		dir, relPath := r.directoryOf(path)
+		if relPath == "" {
+			r.warnf("reload failed: %s is outside of the template directories", path)
+			continue
+		}
+
+		ws, err := r.parseSource(Source{Name: r.sourceName(relPath), Text: string(buf), path: path})
+		if err != nil {
+			r.warnf("reload failed: %v", err)
+			continue
+		}
+		ws.dir = dir
+		r.watched[path] = ws
+	}
+
+	if err := r.assemble(); err != nil {
+		r.warnf("reload failed: %v", err)
+	}
+}
+
+// parseSource parses template file src on its own
+func (r *Renderer) parseSource(src Source) (*watchedSource, error) {
+	t := template.New(src.Name)
+	text := texttemplate.New(src.Name)
+
+	t.Delims(r.options.Delims.Left, r.options.Delims.Right)
+	text.Delims(r.options.Delims.Left, r.options.Delims.Right)
+
+	denied := r.addFuncs(t, text)
+	ws := &watchedSource{
+		name:  src.Name,
+		text:  r.options.isTextFormat(r.options.formatOf(src.Name)),
+		trees: map[string]*parse.Tree{},
+	}
+
+	if ws.text {
+		if _, err := text.Parse(src.Text); err != nil {
+			return nil, parseError(src, err, denied)
+		}
+		for _, tmpl := range text.Templates() {
+			ws.trees[tmpl.Name()] = tmpl.Tree
+		}
+	} else {
+		if _, err := t.Parse(src.Text); err != nil {
+			return nil, parseError(src, err, denied)
+		}
+		for _, tmpl := range t.Templates() {
+			ws.trees[tmpl.Name()] = tmpl.Tree
+		}
+	}
+
+	return ws, nil
+}
+
+// assemble replaces the templates with the watched parse trees, callers hold watchMu
+func (r *Renderer) assemble() error {
+	t, text, _, err := r.newSets()
+	if err != nil {
+		return err
+	}
+
+	// files in directory and Walk order, so {{ define }} overrides are the same as with compile
+	paths := make([]string, 0, len(r.watched))
+	last := map[string]int{}
+	for path, ws := range r.watched {
+		paths = append(paths, path)
+		if dir, ok := last[ws.name]; !ok || ws.dir > dir {
+			last[ws.name] = ws.dir
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := r.watched[paths[i]], r.watched[paths[j]]
+		if a.dir != b.dir {
+			return a.dir < b.dir
+		}
+		return paths[i] < paths[j]
+	})
+
+	for _, path := range paths {
+		ws := r.watched[path]
+		// overridden by a later directory
+		if ws.dir < last[ws.name] {
+			continue
+		}
+
+		for name, tree := range ws.trees {
+			if ws.text {
+				_, err = text.AddParseTree(name, tree)
+			} else {
+				_, err = t.AddParseTree(name, tree)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.t, r.text = t, text
+	r.reloads++
+	r.mu.Unlock()
+
+	return nil
+}
+
+// directoryOf returns the index of the last of Options.Directories containing path and the path relative to it,
+// relPath is "" if no directory contains path
+func (r *Renderer) directoryOf(path string) (int, string) {
+	dirs := r.directories()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return i, rel
+		}
+	}
+
+	return 0, ""
+}
+
+// isTemplateFile reports whether path has one of Options.Extensions
+func (r *Renderer) isTemplateFile(path string) bool {
+	for _, ext := range r.options.Extensions {
+		if filepath.Ext(path) == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchDirs adds dir and its subdirectories to watcher (fsnotify isn't recursive)
+func watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package wutrender
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func Test_Watch(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`<p>{{ partial "card" . }}</p>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.tmpl"), []byte(`v1 {{ . }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt.tmpl"), []byte(`a < {{ . }}`), 0644)
+
+	dev := true
+	r := New(Options{Directory: dir, DevMode: &dev, ReloadDebounce: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Nil(t, r.Watch(ctx))
+	assert.NotNil(t, r.Watch(ctx))
+	assert.True(t, r.isWatching())
+
+	render := func(name string) string {
+		buf, _ := r.Copy().RenderFormat("html", name, "bob")
+		return buf.String()
+	}
+	renders := func(name, expected string) func() bool {
+		return func() bool { return render(name) == expected }
+	}
+
+	assert.Equal(t, render("page"), "<p>v1 bob</p>")
+	buf, _ := r.Copy().RenderFormat("txt", "notes", "b")
+	assert.Equal(t, buf.String(), "a < b")
+
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.tmpl"), []byte(`v2 {{ . }}`), 0644)
+	assert.Eventually(t, renders("page", "<p>v2 bob</p>"), time.Second, 10*time.Millisecond)
+
+	// parse errors keep the old templates
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.tmpl"), []byte(`{{ if }}`), 0644)
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, render("page"), "<p>v2 bob</p>")
+
+	os.MkdirAll(filepath.Join(dir, "users"), 0755)
+	time.Sleep(50 * time.Millisecond)
+	ioutil.WriteFile(filepath.Join(dir, "users", "show.html.tmpl"), []byte(`user {{ . }}`), 0644)
+	assert.Eventually(t, renders("users/show", "user bob"), time.Second, 10*time.Millisecond)
+
+	os.Remove(filepath.Join(dir, "users", "show.html.tmpl"))
+	assert.Eventually(t, func() bool { return !r.Copy().exists("users/show.html") }, time.Second, 10*time.Millisecond)
+
+	r.StopWatching()
+	assert.False(t, r.isWatching())
+
+	// recompiled on every copy again
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.tmpl"), []byte(`v3 {{ . }}`), 0644)
+	assert.Equal(t, render("page"), "<p>v3 bob</p>")
+
+	assert.Nil(t, r.Watch(ctx))
+	cancel()
+	assert.Eventually(t, func() bool { return !r.isWatching() }, time.Second, 10*time.Millisecond)
+
+	fsr := New(Options{FS: fstest.MapFS{"templates/page.html.tmpl": {Data: []byte(`page`)}}})
+	assert.NotNil(t, fsr.Watch(context.Background()))
+}
+
+func Test_WatchDirectories(t *testing.T) {
+	base, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(base)
+	theme, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(theme)
+	ioutil.WriteFile(filepath.Join(base, "page.html.tmpl"), []byte(`<p>{{ partial "card" . }}</p>`), 0644)
+	ioutil.WriteFile(filepath.Join(base, "_card.html.tmpl"), []byte(`base {{ . }}`), 0644)
+	ioutil.WriteFile(filepath.Join(theme, "_card.html.tmpl"), []byte(`theme {{ . }}`), 0644)
+
+	dev := true
+	r := New(Options{Directories: []string{base, theme}, DevMode: &dev, ReloadDebounce: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Nil(t, r.Watch(ctx))
+
+	render := func() string {
+		buf, _ := r.Copy().HTML("page", "bob")
+		return buf.String()
+	}
+	assert.Equal(t, render(), "<p>theme bob</p>")
+
+	// changes of an overridden template don't show through
+	ioutil.WriteFile(filepath.Join(base, "_card.html.tmpl"), []byte(`base v2 {{ . }}`), 0644)
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, render(), "<p>theme bob</p>")
+
+	os.Remove(filepath.Join(theme, "_card.html.tmpl"))
+	assert.Eventually(t, func() bool { return render() == "<p>base v2 bob</p>" }, time.Second, 10*time.Millisecond)
+}
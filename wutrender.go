@@ -41,20 +41,39 @@ So file with "templates/sessions/new.html.tmpl" path will be available to render
 
 Layouts and partials support.
 
-wutrender requires Go 1.2 or newer.
+wutrender requires Go 1.16 or newer.
 */
 package wutrender
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/8protons/wutenv"
+	"github.com/8protons/wutrender/funcs"
+	"github.com/fsnotify/fsnotify"
 	"html/template"
+	"io"
+	"io/fs"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+	"time"
 )
 
 const (
@@ -62,18 +81,66 @@ const (
 	ContentJSON = "application/json; charset=utf-8"
 	ContentHTML = "text/html; charset=utf-8"
 	ContentJS   = "application/javascript; charset=utf-8"
+	ContentXML  = "application/xml; charset=utf-8"
 )
 
 // Helper functions placeholders
 var helperFunctions = template.FuncMap{
-	"yield": func() (string, error) {
+	"yield": func(section ...string) (string, error) {
 		return "", fmt.Errorf("yield called without layout")
 	},
+	"contentFor":    contentFor,
+	"endContentFor": endContentFor,
 	"partial": func(name string, binding ...interface{}) (string, error) {
 		return "", fmt.Errorf("partial called without implementation")
 	},
+	"partialIsolated": func(name string, pairs ...interface{}) (string, error) {
+		return "", fmt.Errorf("partialIsolated called without implementation")
+	},
+	"render": func(name string, binding ...interface{}) (string, error) {
+		return "", fmt.Errorf("render called without implementation")
+	},
+	"partialRaw": func(name string, binding ...interface{}) (string, error) {
+		return "", fmt.Errorf("partialRaw called without implementation")
+	},
+	"cachedPartial": func(key string, ttl interface{}, name string, binding ...interface{}) (string, error) {
+		return "", fmt.Errorf("cachedPartial called without implementation")
+	},
+	"macro": func(name string, args ...interface{}) (string, error) {
+		return "", fmt.Errorf("macro called without implementation")
+	},
+	"criticalCSS": func(name string) (string, error) {
+		return "", fmt.Errorf("criticalCSS called without implementation")
+	},
+	"requestID": func() string {
+		return ""
+	},
+	"useLayout": func(key string) (string, error) {
+		return "", fmt.Errorf("useLayout called without implementation")
+	},
+	"t": func(key string, args ...interface{}) string {
+		return key
+	},
+	"extends": func(layout string) string {
+		return ""
+	},
+	"esc": func(v interface{}) string {
+		return fmt.Sprint(v)
+	},
+	"local": func(key string) interface{} {
+		return nil
+	},
+	"variant": func(experiment string) string {
+		return ""
+	},
+	"csrfToken": func() string {
+		return ""
+	},
 }
 
+// Formats parsed with text/template instead of html/template (no HTML escaping)
+var textFormats = []string{"go", "css", "txt"}
+
 // Delims represents a set of Left and Right delimiters for HTML template rendering
 type Delims struct {
 	// Left delimiter, defaults to {{
@@ -85,37 +152,333 @@ type Delims struct {
 type Options struct {
 	// Directory to load templates. Default is "templates"
 	Directory string
+	// Directories to load templates from instead of Directory, later ones override templates of the same name
+	// in earlier ones, e.g. {"templates", "themes/dark"}. Defaults to nil.
+	Directories []string
 	// Layout template name. Will not render a layout if "". Defaults to "".
 	Layout string
+	// Layouts by format, e.g. {"html": "layouts/app", "xml": "layouts/feed", "txt": "layouts/email"} renders
+	// "layouts/feed.xml" around xml templates. An "html" entry replaces Layout, "" renders a format without layout.
+	// Layouts of other formats than html return the content with {{ yield }}, they don't extend other layouts.
+	// Defaults to nil (only html has a layout).
+	Layouts map[string]string
 	// Extensions to parse template files from. Defaults to [".tmpl"]
 	Extensions []string
 	// Template delimiters
 	Delims Delims
 	// Helper functions. Defaults to [].
 	Funcs []template.FuncMap
+	// Install the helper library of the funcs package (upper, truncate, date, add, list, default, ...).
+	// DefaultFuncs win on name conflicts, Options.Funcs override both. Defaults to false.
+	EnableBuiltins bool
+	// Pipe "go" format output through go/format. Defaults to false.
+	FormatGo bool
+	// Environment variables readable with the env helper. Defaults to [].
+	EnvWhitelist []string
+	// Custom lookup for the env helper, overrides EnvWhitelist. Defaults to nil.
+	EnvFunc func(key string) (string, bool)
+	// URL builder for the route helper. Defaults to nil.
+	RouteResolver func(name string, args ...interface{}) (string, error)
+	// Parameter names of macros - templates called with positional args: {{ macro "button" "Save" "primary" }}
+	// renders {{ define "button" }} with {"Text": "Save", "Variant": "primary"} for {"button": {"Text", "Variant"}}.
+	// Defaults to nil.
+	Macros map[string][]string
+	// Props accepted by partials, keyed by partial name ("cards/card"). A partial with a schema must get
+	// key-value pairs, which are validated before rendering. Defaults to nil.
+	PartialSchemas map[string][]PropSpec
+	// Maximum nesting of partials within one render. Defaults to 50.
+	MaxPartialDepth int
+	// Render content before the layout and pass the returned value to the layout as binding.
+	// content is reused by later renders, copy it to keep it. Defaults to nil (content is rendered lazily by yield with the original binding).
+	SecondPass func(content []byte, binding interface{}) interface{}
+	// Layouts selectable by key from content templates with {{ useLayout "admin" }}. Defaults to nil.
+	LayoutRegistry map[string]string
+	// Derive the text part of Email from the html part when there is no "name.txt" template. Defaults to false.
+	AutoTextFromHTML bool
+	// Render deadlines by full template name, e.g. {"reports/annual.html": time.Second}.
+	// Renders over the deadline return an error wrapping context.DeadlineExceeded. Defaults to nil.
+	TemplateTimeouts map[string]time.Duration
+	// Deadline of every render, partials and yield included. Renders over it return context.DeadlineExceeded,
+	// see RenderFormatContext. Defaults to 0 (no deadline).
+	RenderTimeout time.Duration
+	// Coalesce NotifyChange calls within this window into one Reload. Defaults to 0 (reload on every change).
+	ReloadDebounce time.Duration
+	// Skip items which fail to render in StreamEach instead of aborting. Defaults to false.
+	StreamSkipErrors bool
+	// Format Negotiate renders for requests without a matching Accept header. Defaults to "html".
+	NegotiateDefault string
+	// Indent of JSON output, e.g. "  ". Defaults to "" (compact).
+	JSONIndent string
+	// Keep <, > and & in JSON strings instead of escaping them to \u003c, \u003e and \u0026. Defaults to false.
+	JSONUnescapeHTML bool
+	// Prepended to JSON output against JSON hijacking, e.g. ")]}',\n". Defaults to "".
+	JSONPrefix string
+	// Indent of XMLData output, e.g. "  ". Defaults to "" (no line breaks).
+	XMLIndent string
+	// Smallest body WriteHTMLGzip and Compress compress, smaller ones are sent as is. Defaults to 1400 (about one TCP packet).
+	GzipMinBytes int
+	// Gzip the output of write helpers (WriteHTML, WriteJS, ...) of copies with a request (SetRequest)
+	// when its Accept-Encoding allows it. WriteHTMLStream and WriteHTMLCached are not compressed. Defaults to false.
+	Compress bool
+	// Record rendered template names for Renderer.UnusedTemplates. Defaults to false.
+	TrackUsage bool
+	// Rewrite relative href and src attributes of html output to absolute URLs, e.g. for emails.
+	// Defaults to "" (no rewriting).
+	AbsoluteBaseURL string
+	// Collapse insignificant whitespace of html output with NormalizeHTML. Defaults to false.
+	NormalizeHTML bool
+	// Add slugified ids to headings of html output with HeadingAnchors. With SecondPass the content
+	// gets them before it's passed to SecondPass, e.g. for the toc helper. Defaults to false.
+	HeadingAnchors bool
+	// End output of every format with exactly one newline if true, strip trailing newlines if false.
+	// Defaults to nil (output is left as is).
+	EnsureTrailingNewline *bool
+	// Prepended to html output rendered without layout (standalone fragments), e.g. "<!DOCTYPE html>\n".
+	// Skipped if the output already starts with a doctype. Defaults to "".
+	HTMLPreamble string
+	// Minify functions keyed by format ("html", "js", "css"), applied last to the rendered output.
+	// The returned slice is reused by later renders, so it must not be shared (e.g. cached). Defaults to nil.
+	Minifiers map[string]func([]byte) ([]byte, error)
+	// Return an error from yield when a layout is rendered without content (RenderLayout).
+	// Defaults to false (yield returns "").
+	StrictYield bool
+	// Return an error from the cached helper for keys without a fragment (SetCachedFragment).
+	// Defaults to false (cached returns "").
+	StrictFragments bool
+	// Called by RenderFormat for missing templates, the returned buffer is used as output
+	// unless the error is ErrTemplateNotFound. Write helpers reuse the buffer, return a new one every time.
+	// Defaults to nil.
+	OnMissingTemplate func(name, format string) (*bytes.Buffer, error)
+	// Called by Write helpers when rendering fails, e.g. to log the error and render a safe error page.
+	// Defaults to nil (500 response with the error text in development, "Internal Server Error" otherwise).
+	OnError func(rw http.ResponseWriter, err error)
+	// Helpers templates may call, e.g. for templates authored by tenants. Built-in yield, contentFor, extends
+	// and partial are always available, other built-in helpers must be listed. Defaults to nil (all helpers).
+	AllowedFuncs []string
+	// Syntax highlighter of the highlightCode helper, e.g. chroma. Defaults to nil (escaped <pre><code>).
+	Highlighter func(lang, code string) (template.HTML, error)
+	// Store of partials cached by cachedPartial, e.g. a RedisStore shared by all instances.
+	// Defaults to nil (a MemoryStore of the Renderer).
+	CacheStore CacheStore
+	// Current time of the timeAgo helper, e.g. a fixed time in tests. Defaults to nil (time.Now).
+	Now func() time.Time
+	// Intrinsic size of images for the img helper, ok is false if unknown. Defaults to nil.
+	ImageInfo func(src string) (w, h int, ok bool)
+	// Locale of the currency helper, one of "en-US", "en-GB", "de-DE", "fr-FR". Defaults to "en-US".
+	// It's also the locale of the t helper and localized templates, see Locales.
+	Locale string
+	// Locales of the t helper catalogs and localized templates ("sessions/new.html.de" is rendered for "de"
+	// and "de-AT" instead of "sessions/new.html"). The first one is the fallback. Defaults to nil.
+	Locales []string
+	// Directory of "{locale}.json" message catalogs, inside Options.FS if it's set. Defaults to "locales".
+	LocalesDirectory string
+	// Decoders of other catalog formats by extension, e.g. {".yaml": yaml.Unmarshal}. Defaults to nil (JSON only).
+	CatalogDecoders map[string]func(data []byte, v interface{}) error
+	// Recompile templates on every Copy() (and skip caches) if true, clone them if false.
+	// Defaults to nil (wutenv.IsDev).
+	DevMode *bool
+	// Renderer with shared templates (partials, layouts) this renderer can use without loading them again.
+	// Own templates override the base ones with the same name. Defaults to nil.
+	BaseRenderer *Renderer
+	// Logger for warnings. Defaults to the standard logger.
+	Logger *log.Logger
+	// Filesystem to load Directory from, e.g. embed.FS with //go:embed templates. Defaults to nil (disk).
+	FS fs.FS
+	// Format for files without a format segment, e.g. "html" registers "home.tmpl" as "home.html".
+	// Defaults to "" (registered as "home").
+	DefaultSourceFormat string
+	// Map keys missing from bindings, the missingkey option of html/template: "zero" (zero value),
+	// "error" (the render fails) or "invalid" (printed as "" in html, <no value> in text formats).
+	// Missing struct fields are always an error. Defaults to "" ("invalid").
+	MissingKey string
+	// Formats parsed with text/template (no HTML escaping) in addition to "go", "css" and "txt",
+	// e.g. "csv" or "md". Defaults to nil.
+	TextFormats []string
+	// Escape functions for custom formats applied by the esc helper, e.g. {"latex": escapeLaTeX}.
+	// Formats with an escaper are parsed with text/template. Defaults to nil.
+	Escapers map[string]func(string) string
+	// Template engines by file extension, e.g. {".jet": jet.New()}. Their files are compiled by the engine
+	// instead of html/template and rendered with the usual names, formats and layouts, see Engine.
+	// They are not embedded by GenerateGo and not reparsed by Watch. Defaults to nil.
+	Engines map[string]Engine
+}
+
+// PropSpec describes a partial prop in Options.PartialSchemas
+type PropSpec struct {
+	Name     string
+	Required bool
+	// Go type of the value as printed by %T, e.g. "string", "[]int", "template.HTML". Empty allows any type.
+	Type string
 }
 
 // Renderer struct
 type Renderer struct {
 	t       *template.Template
+	text    *texttemplate.Template
 	options Options
+
+	// Templates embedded by NewFromSources, Options.Directory is not read when set
+	sources []Source
+
+	// Guards t and text replaced by Reload
+	mu sync.RWMutex
+	// Templates compiled by Options.Engines, replaced with t and text
+	engineSet *engineSet
+	// Pending debounced reload
+	reloadTimer *time.Timer
+	reloadMu    sync.Mutex
+	// Number of reloads, for tests
+	reloads int
+
+	// File watcher started by Watch, see watch.go
+	watcher      *fsnotify.Watcher
+	stopWatching context.CancelFunc
+	watched      map[string]*watchedSource
+	changed      map[string]bool
+	changeTimer  *time.Timer
+	watchMu      sync.Mutex
+
+	// Rendered criticalCSS templates (production only)
+	css   map[string]template.CSS
+	cssMu sync.RWMutex
+
+	// Template rendered instead of any other while not ""
+	maintenance   string
+	maintenanceMu sync.RWMutex
+
+	// Names of rendered templates with Options.TrackUsage
+	used   map[string]bool
+	usedMu sync.Mutex
+
+	// Pre-rendered HTML returned by the cached helper
+	fragments   map[string]template.HTML
+	fragmentsMu sync.RWMutex
+
+	// Partials rendered by cachedPartial without Options.CacheStore (production only)
+	memoryStore *MemoryStore
+
+	// Messages of the t helper by locale, loaded once by New
+	catalogs map[string]map[string]string
+
+	// Templates of ErrorPages by status
+	errorPages   map[int]string
+	errorPagesMu sync.RWMutex
 }
 
 // Template copy - has all rendering methods
 type TemplateCopy struct {
-	t      *template.Template
-	layout string
+	t        *template.Template
+	text     *texttemplate.Template
+	engines  *engineSet
+	layout   string
+	options  Options
+	renderer *Renderer
+
+	// Current partial nesting
+	depth int
+
+	// Maintenance template at the time of Copy()
+	maintenance string
+
+	// Directory with template overrides, see SetTheme
+	theme string
+
+	// Request-scoped values returned by the local helper
+	locals map[string]interface{}
+
+	// Whether the last render used a layout
+	withLayout bool
+	// Renders without layout and preamble, see Fragment
+	fragment bool
+	// Layout chosen by the caller for the current render, useLayout doesn't change it, see HTMLWithLayout
+	fixedLayout bool
+
+	// CSRF token injected into <head>, see SetCSRF
+	csrf string
+
+	// Request the output is written for, see SetRequest
+	request *http.Request
+
+	// Locale of the t helper and localized templates, see SetLocale
+	locale string
+
+	// Serializes renders of the copy, helpers read the per-render state below
+	renderMu sync.Mutex
+	// yield of the current layout render, nil without layout
+	yield func(section ...string) (template.HTML, error)
+	// Format of the current text render, for the esc helper
+	format string
+	// Context of the current render, nil if it can't be cancelled
+	ctx context.Context
+
+	// A render timed out and still holds renderMu, see renderTimeout
+	abandoned bool
 }
 
 func New(opt ...Options) *Renderer {
+	r, err := newRenderer(opt...)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// NewE is New returning template read and parse errors (*ParseError) instead of panicking
+func NewE(opt ...Options) (*Renderer, error) {
+	return newRenderer(opt...)
+}
+
+// newRenderer creates a Renderer and returns compile errors instead of panicking
+func newRenderer(opt ...Options) (*Renderer, error) {
 	options := prepareOptions(opt)
 	r := &Renderer{
 		options: options,
+		css:     map[string]template.CSS{},
+	}
+
+	if err := r.init(); err != nil {
+		return nil, err
 	}
 
-	r.t = r.compile()
+	return r, nil
+}
+
+// init compiles templates of a new Renderer
+func (r *Renderer) init() error {
+	r.checkFuncs()
+	r.memoryStore = NewMemoryStore()
 
-	return r
+	t, text, engines, err := r.compile()
+	if err != nil {
+		return err
+	}
+	r.t = t
+	r.text = text
+	r.engineSet = engines
+
+	r.catalogs, err = r.loadCatalogs()
+
+	return err
+}
+
+// checkFuncs warns about Options.Funcs which are replaced by the built-in helpers (yield, partial, ...)
+func (r *Renderer) checkFuncs() {
+	var names []string
+
+	for _, funcs := range r.options.Funcs {
+		for name := range funcs {
+			if _, ok := helperFunctions[name]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.warnf("helper %q from Options.Funcs is reserved and will be replaced by the built-in one", name)
+	}
 }
 
 // Default Renderer options
@@ -132,216 +495,2408 @@ func prepareOptions(options []Options) Options {
 	if len(opt.Extensions) == 0 {
 		opt.Extensions = []string{".tmpl"}
 	}
+	if opt.MaxPartialDepth == 0 {
+		opt.MaxPartialDepth = 50
+	}
+	if opt.GzipMinBytes == 0 {
+		opt.GzipMinBytes = 1400
+	}
+	if opt.NegotiateDefault == "" {
+		opt.NegotiateDefault = "html"
+	}
+	if opt.LocalesDirectory == "" {
+		opt.LocalesDirectory = "locales"
+	}
 
 	return opt
 }
 
-func (r *Renderer) compile() *template.Template {
+func (r *Renderer) compile() (*template.Template, *texttemplate.Template, *engineSet, error) {
+	t, text, denied, err := r.newSets()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sources, err := r.loadSources()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, src := range sources {
+		if r.sourceEngine(src) != nil {
+			continue
+		}
+
+		if r.options.isTextFormat(r.options.formatOf(src.Name)) {
+			_, err = text.New(src.Name).Parse(src.Text)
+		} else {
+			_, err = t.New(src.Name).Parse(src.Text)
+		}
+
+		if err != nil {
+			return nil, nil, nil, parseError(src, err, denied)
+		}
+	}
+
+	engines, err := r.compileEngines(sources)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return t, text, engines, nil
+}
+
+// newSets returns empty template sets with helpers and base templates, and names of helpers denied by Options.AllowedFuncs
+func (r *Renderer) newSets() (*template.Template, *texttemplate.Template, []string, error) {
 	t := template.New(r.options.Directory)
+	text := texttemplate.New(r.options.Directory)
 
 	t.Delims(r.options.Delims.Left, r.options.Delims.Right)
+	text.Delims(r.options.Delims.Left, r.options.Delims.Right)
 
 	template.Must(t.Parse("wut!"))
 
-	filepath.Walk(r.options.Directory, func(path string, info os.FileInfo, err error) error {
-		relPath, err := filepath.Rel(r.options.Directory, path)
-		if err != nil {
-			return err
-		}
+	// templates added to the sets share the option, so partials and yield honor it too
+	switch r.options.MissingKey {
+	case "":
+	case "zero", "error", "invalid":
+		t.Option("missingkey=" + r.options.MissingKey)
+		text.Option("missingkey=" + r.options.MissingKey)
+	default:
+		return nil, nil, nil, fmt.Errorf("wutrender: Options.MissingKey must be \"zero\", \"error\" or \"invalid\", got %q", r.options.MissingKey)
+	}
 
-		fileExt := filepath.Ext(relPath)
+	denied := r.addFuncs(t, text)
 
-		for _, v := range r.options.Extensions {
-			if v == fileExt {
+	if err := r.addBaseTemplates(t, text); err != nil {
+		return nil, nil, nil, err
+	}
 
-				// Read file and panic on error
-				buf, err := ioutil.ReadFile(path)
-				if err != nil {
-					panic(err)
-				}
+	return t, text, denied, nil
+}
 
-				name := strings.TrimSuffix(relPath, filepath.Ext(relPath))
-				tmpl := t.New(filepath.ToSlash(name))
+// addFuncs adds helpers to t and text and returns names of helpers denied by Options.AllowedFuncs
+func (r *Renderer) addFuncs(t *template.Template, text *texttemplate.Template) []string {
+	base := r.options.BaseRenderer
 
-				// add our funcmaps
-				for _, funcs := range r.options.Funcs {
-					t.Funcs(funcs)
-				}
+	var funcMaps []template.FuncMap
+	if r.options.EnableBuiltins || (base != nil && base.options.EnableBuiltins) {
+		funcMaps = append(funcMaps, funcs.FuncMap())
+	}
+	funcMaps = append(funcMaps, DefaultFuncs, r.optionFuncs())
 
-				t.Funcs(helperFunctions)
+	// base templates may use base helpers
+	if base != nil {
+		funcMaps = append(funcMaps, base.options.Funcs...)
+	}
 
-				// template.Must(tmpl.Parse(string(buf)))
-				_, err = tmpl.Parse(string(buf))
-				if err != nil {
-					panic(err)
-				}
-				break
-			}
+	// add our funcmaps
+	funcMaps = append(funcMaps, r.options.Funcs...)
+
+	var denied []string
+	for _, funcMap := range funcMaps {
+		funcMap, d := r.allowedFuncs(funcMap, nil)
+		denied = append(denied, d...)
+
+		t.Funcs(funcMap)
+		text.Funcs(texttemplate.FuncMap(funcMap))
+	}
+
+	helpers, d := r.allowedFuncs(helperFunctions, layoutHelpers)
+	denied = append(denied, d...)
+
+	t.Funcs(helpers)
+	text.Funcs(texttemplate.FuncMap(helpers))
+
+	return denied
+}
+
+// layoutHelpers are the helpers of layouts and partials, allowed whatever Options.AllowedFuncs lists
+var layoutHelpers = []string{"yield", "contentFor", "endContentFor", "extends", "partial"}
+
+// parseError wraps parse error of src, naming the helper if it's denied by Options.AllowedFuncs
+func parseError(src Source, err error, denied []string) error {
+	for _, name := range denied {
+		if strings.Contains(err.Error(), fmt.Sprintf("function %q not defined", name)) {
+			err = fmt.Errorf("%w (%q is not in Options.AllowedFuncs)", err, name)
+			break
 		}
+	}
 
-		return nil
-	}) // end Walk
+	return newParseError(src, err)
+}
+
+// allowedFuncs returns funcs in Options.AllowedFuncs or exempt (all if it's empty) and names of the others
+func (r *Renderer) allowedFuncs(funcs template.FuncMap, exempt []string) (template.FuncMap, []string) {
+	if len(r.options.AllowedFuncs) == 0 {
+		return funcs, nil
+	}
+
+	allowed := template.FuncMap{}
+	var denied []string
 
-	return t
+	for name, fn := range funcs {
+		if containsName(r.options.AllowedFuncs, name) || containsName(exempt, name) {
+			allowed[name] = fn
+		} else {
+			denied = append(denied, name)
+		}
+	}
+
+	return allowed, denied
 }
 
-// Return *TemplateCopy to guarantee cleanness of the source templates.
-func (r *Renderer) Copy() *TemplateCopy {
-	var tc *template.Template
+// loadSources reads template files from Options.Directory (or Options.Directories), or returns the embedded sources
+func (r *Renderer) loadSources() ([]Source, error) {
+	if r.sources != nil {
+		return r.sources, nil
+	}
 
-	// Recompile template
-	if wutenv.IsDev {
-		tc = r.compile()
-	} else {
+	var sources []Source
+	for _, dir := range r.directories() {
+		var found []Source
 		var err error
-		tc, err = r.t.Clone()
+		if r.options.FS != nil {
+			found, err = r.loadFSSources(dir)
+		} else {
+			found, err = r.loadDirSources(dir)
+		}
 
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
+		sources = append(sources, found...)
 	}
 
-	return &TemplateCopy{
-		t:      tc,
-		layout: r.options.Layout,
-	}
+	return overrideSources(sources), nil
 }
 
-// Render HTML with layout support
-func (tmpl *TemplateCopy) HTML(name string, binding interface{}) (*bytes.Buffer, error) {
-	return tmpl.RenderFormat("html", name, binding)
+// directories returns Options.Directories, or Options.Directory if there are none
+func (r *Renderer) directories() []string {
+	if len(r.options.Directories) > 0 {
+		return r.options.Directories
+	}
+
+	return []string{r.options.Directory}
 }
 
-// Write HTML to ResponseWriter
-func (tmpl *TemplateCopy) WriteHTML(rw http.ResponseWriter, status int, name string, binding interface{}) {
-	html, err := tmpl.HTML(name, binding)
+// overrideSources drops sources replaced by a later source of the same name
+func overrideSources(sources []Source) []Source {
+	last := make(map[string]int, len(sources))
+	for i, src := range sources {
+		last[src.Name] = i
+	}
 
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
-		return
+	kept := sources[:0]
+	for i, src := range sources {
+		if last[src.Name] == i {
+			kept = append(kept, src)
+		}
 	}
 
-	rw.Header().Set(ContentType, ContentHTML)
-	rw.WriteHeader(status)
-	rw.Write(html.Bytes())
+	return kept
 }
 
-// Shortcut for RenderFormat("js", ...) - render Javascript file
-func (tmpl *TemplateCopy) JS(name string, binding interface{}) (*bytes.Buffer, error) {
-	return tmpl.RenderFormat("js", name, binding)
+// loadDirSources reads template files from dir
+func (r *Renderer) loadDirSources(dir string) ([]Source, error) {
+	var sources []Source
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if r.isSourceExt(filepath.Ext(relPath)) {
+			buf, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			sources = append(sources, Source{Name: r.sourceName(relPath), Text: string(buf), path: path})
+		}
+
+		return nil
+	}) // end Walk
+
+	return sources, err
 }
 
-// Write JS file to ResponseWriter
-func (tmpl *TemplateCopy) WriteJS(rw http.ResponseWriter, status int, name string, binding interface{}) {
-	html, err := tmpl.RenderFormat("js", name, binding)
+// isSourceExt reports whether files with ext are templates: one of Options.Extensions or Options.Engines
+func (r *Renderer) isSourceExt(ext string) bool {
+	for _, v := range r.options.Extensions {
+		if v == ext {
+			return true
+		}
+	}
 
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
-		return
+	_, ok := r.options.Engines[ext]
+
+	return ok
+}
+
+// sourceName returns template name of file at relPath of Options.Directory: "users/show.html.tmpl" is "users/show.html"
+func (r *Renderer) sourceName(relPath string) string {
+	name := filepath.ToSlash(strings.TrimSuffix(relPath, filepath.Ext(relPath)))
+	if filepath.Ext(name) == "" && r.options.DefaultSourceFormat != "" {
+		name += "." + r.options.DefaultSourceFormat
 	}
 
-	rw.Header().Set(ContentType, ContentJS)
-	rw.WriteHeader(status)
-	rw.Write(html.Bytes())
+	return name
 }
 
-// General function to render template with "name.{format}" scheme
-func (tmpl *TemplateCopy) RenderFormat(format string, name string, binding interface{}) (*bytes.Buffer, error) {
+// loadFSSources reads template files from dir of Options.FS
+func (r *Renderer) loadFSSources(dir string) ([]Source, error) {
+	var sources []Source
+	root := path.Clean(filepath.ToSlash(dir))
 
-	// Add partial support
-	addPartial(tmpl.t)
+	err := fs.WalkDir(r.options.FS, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 
-	fullName := name + "." + format
+		if d.IsDir() {
+			return nil
+		}
 
-	// Set yield function (layout)
-	if format == "html" && tmpl.layout != "" {
-		addYield(tmpl.t, fullName, binding)
-		fullName = tmpl.layout + ".html"
+		fileExt := path.Ext(p)
+
+		if r.isSourceExt(fileExt) {
+			buf, err := fs.ReadFile(r.options.FS, p)
+			if err != nil {
+				return err
+			}
+
+			name := strings.TrimSuffix(strings.TrimPrefix(p, root+"/"), fileExt)
+			if root == "." {
+				name = strings.TrimSuffix(p, fileExt)
+			}
+			if path.Ext(name) == "" && r.options.DefaultSourceFormat != "" {
+				name += "." + r.options.DefaultSourceFormat
+			}
+
+			sources = append(sources, Source{Name: name, Text: string(buf), path: p})
+		}
+
+		return nil
+	})
+
+	return sources, err
+}
+
+// isDev reports whether Options.DevMode (or wutenv.IsDev) is on
+func (r *Renderer) isDev() bool {
+	if r.options.DevMode != nil {
+		return *r.options.DevMode
 	}
 
-	return executeTemplate(tmpl.t, fullName, binding)
+	return wutenv.IsDev
 }
 
-// Override default layout
-func (tmpl *TemplateCopy) SetLayout(layout string) *TemplateCopy {
-	tmpl.layout = layout
+// warnf logs a warning with Options.Logger
+func (r *Renderer) warnf(format string, v ...interface{}) {
+	if r.options.Logger != nil {
+		r.options.Logger.Printf("wutrender: "+format, v...)
+	} else {
+		log.Printf("wutrender: "+format, v...)
+	}
+}
 
-	return tmpl
+// UserTemplateCount returns the number of loaded templates: template files, their {{ define }} blocks and
+// templates of Options.BaseRenderer, excluding internal templates such as the "wut!" root
+func (r *Renderer) UserTemplateCount() int {
+	count := 0
+	t, text := r.templates()
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Name() != t.Name() {
+			count++
+		}
+	}
+
+	for _, tmpl := range text.Templates() {
+		if tmpl.Name() != text.Name() {
+			count++
+		}
+	}
+
+	return count
 }
 
-// Set template.FuncMap - it's safe and does not change source templates
-func (tmpl *TemplateCopy) SetFuncs(funcs template.FuncMap) *TemplateCopy {
-	tmpl.t.Funcs(funcs)
+// Exists reports whether template fullName ("users/show.html") is loaded, e.g. before rendering a URL slug.
+// Internal templates such as the "wut!" root don't exist.
+func (r *Renderer) Exists(fullName string) bool {
+	t, text, engines := r.listedTemplates()
 
-	return tmpl
+	if fullName != t.Name() {
+		if r.options.isTextFormat(r.options.formatOf(fullName)) {
+			if tmpl := text.Lookup(fullName); tmpl != nil && tmpl.Tree != nil {
+				return true
+			}
+		} else if tmpl := t.Lookup(fullName); tmpl != nil && tmpl.Tree != nil {
+			return true
+		}
+	}
+
+	return engines.lookup(fullName) != nil
 }
 
-// Add yield keyword
-func addYield(t *template.Template, name string, binding interface{}) {
-	funcs := template.FuncMap{
-		"yield": func() (template.HTML, error) {
-			buf, err := executeTemplate(t, name, binding)
-			// return safe html here since we are rendering our own template
-			return template.HTML(buf.String()), err
-		},
+// TemplateNames returns the sorted full names of loaded templates ("users/show.html") including {{ define }}
+// and engine templates, only those of formats if any are given: TemplateNames("html", "txt")
+func (r *Renderer) TemplateNames(formats ...string) []string {
+	t, text, engines := r.listedTemplates()
+
+	var names []string
+	for _, tmpl := range t.Templates() {
+		if tmpl.Name() != t.Name() && tmpl.Tree != nil {
+			names = append(names, tmpl.Name())
+		}
+	}
+	for _, tmpl := range text.Templates() {
+		if tmpl.Name() != text.Name() && tmpl.Tree != nil {
+			names = append(names, tmpl.Name())
+		}
+	}
+
+	names = append(names, engines.allNames()...)
+
+	wanted := map[string]bool{}
+	for _, format := range formats {
+		wanted[format] = true
+	}
+
+	listed := make([]string, 0, len(names))
+	for _, name := range names {
+		if len(wanted) == 0 || wanted[r.options.formatOf(name)] {
+			listed = append(listed, name)
+		}
 	}
-	t.Funcs(funcs)
+	sort.Strings(listed)
+
+	return listed
 }
 
-// Add partial keyword
-func addPartial(t *template.Template) {
-	funcs := template.FuncMap{
-		"partial": func(name string, pairs ...interface{}) (template.HTML, error) {
-			binding, err := mapFromPairs(pairs...)
+// listedTemplates returns the template sets of Exists and TemplateNames, in development they are
+// recompiled like by Copy so new files are found. The current sets are used if that fails.
+func (r *Renderer) listedTemplates() (*template.Template, *texttemplate.Template, *engineSet) {
+	if r.isDev() && !r.isWatching() {
+		if t, text, engines, err := r.compile(); err == nil {
+			return t, text, engines
+		}
+	}
 
-			if err != nil {
-				return "", err
-			}
+	t, text := r.templates()
 
-			dir, filename := filepath.Split(name)
+	return t, text, r.engines()
+}
 
-			buf, err := executeTemplate(t, dir+"_"+filename+".html", binding)
+// templates returns the current source template sets
+func (r *Renderer) templates() (*template.Template, *texttemplate.Template) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-			// return safe html
-			return template.HTML(buf.String()), err
-		},
+	return r.t, r.text
+}
+
+// Reload recompiles templates, the old ones are kept on error
+func (r *Renderer) Reload() error {
+	t, text, engines, err := r.compile()
+	if err != nil {
+		return err
 	}
-	t.Funcs(funcs)
+
+	r.mu.Lock()
+	r.t, r.text, r.engineSet = t, text, engines
+	r.reloads++
+	r.mu.Unlock()
+
+	return nil
 }
 
-// mapFromPairs converts interface parameters to a string map for partial binding
-func mapFromPairs(pairs ...interface{}) (interface{}, error) {
-	length := len(pairs)
+// NotifyChange tells the renderer that template files have changed (e.g. from a file watcher).
+// Templates are reloaded in background once no changes were notified for Options.ReloadDebounce.
+func (r *Renderer) NotifyChange() {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
 
-	if length == 1 {
-		return pairs[0], nil
+	if r.reloadTimer != nil {
+		r.reloadTimer.Stop()
 	}
 
-	if length%2 != 0 {
-		return nil, fmt.Errorf("wutrender: number of parameters must be multiple of 2, got %v", pairs)
+	r.reloadTimer = time.AfterFunc(r.options.ReloadDebounce, func() {
+		if err := r.Reload(); err != nil {
+			r.warnf("reload failed: %v", err)
+		}
+	})
+}
+
+// addBaseTemplates shares parse trees of Options.BaseRenderer templates (copies clone them before execution)
+func (r *Renderer) addBaseTemplates(t *template.Template, text *texttemplate.Template) error {
+	base := r.options.BaseRenderer
+	if base == nil {
+		return nil
 	}
 
-	m := make(map[string]interface{}, length/2)
+	baseT, baseText := base.templates()
 
-	for i := 0; i < length; i += 2 {
-		v := pairs[i]
-		switch v.(type) {
-		case string:
-			m[v.(string)] = pairs[i+1]
-		default:
-			return nil, fmt.Errorf("wutrender: pairs should be in format \"string => interface{}\", got %v, %v", v, pairs[i+1])
+	for _, bt := range baseT.Templates() {
+		if bt.Name() != baseT.Name() && bt.Tree != nil {
+			if _, err := t.AddParseTree(bt.Name(), bt.Tree); err != nil {
+				return err
+			}
 		}
 	}
-	return m, nil
+
+	for _, bt := range baseText.Templates() {
+		if bt.Name() != baseText.Name() && bt.Tree != nil {
+			if _, err := text.AddParseTree(bt.Name(), bt.Tree); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Return *TemplateCopy to guarantee cleanness of the source templates.
+func (r *Renderer) Copy() *TemplateCopy {
+	tmpl, err := r.copy()
+	if err != nil {
+		panic(err)
+	}
+
+	return tmpl
 }
 
-func executeTemplate(t *template.Template, name string, binding interface{}) (*bytes.Buffer, error) {
-	buf := new(bytes.Buffer)
-	err := t.ExecuteTemplate(buf, name, binding)
+// CopyFrom compiles templates of dir layered over the renderer templates and returns a copy rendering them,
+// e.g. for previews of edited templates. Nothing is cached, so it's much slower than Copy().
+func (r *Renderer) CopyFrom(dir string) (*TemplateCopy, error) {
+	prod := false
+
+	opt := r.options
+	opt.Directory = dir
+	opt.Directories = nil
+	opt.FS = nil
+	opt.BaseRenderer = r
+	// already compiled, clone it
+	opt.DevMode = &prod
 
+	preview, err := newRenderer(opt)
 	if err != nil {
-		return bytes.NewBufferString(err.Error()), err
+		return nil, err
+	}
+
+	return preview.copy()
+}
+
+// copy is Copy without panics
+func (r *Renderer) copy() (*TemplateCopy, error) {
+	var tc *template.Template
+	var text *texttemplate.Template
+	var engines *engineSet
+	var err error
+
+	// Recompile template, unless Watch keeps them up to date
+	if r.isDev() && !r.isWatching() {
+		tc, text, engines, err = r.compile()
+	} else {
+		t, txt := r.templates()
+		engines = r.engines()
+
+		tc, err = t.Clone()
+		if err == nil {
+			text, err = txt.Clone()
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.maintenanceMu.RLock()
+	maintenance := r.maintenance
+	r.maintenanceMu.RUnlock()
+
+	tmpl := &TemplateCopy{
+		t:           tc,
+		text:        text,
+		engines:     engines,
+		layout:      r.options.layoutOf("html"),
+		options:     r.options,
+		renderer:    r,
+		maintenance: maintenance,
+		locale:      r.options.Locale,
+	}
+	tmpl.addHelpers()
+
+	return tmpl, nil
+}
+
+// SetMaintenance makes every render of the following copies render the name.html template instead,
+// Write helpers respond with 503 status
+func (r *Renderer) SetMaintenance(name string) {
+	r.maintenanceMu.Lock()
+	r.maintenance = name
+	r.maintenanceMu.Unlock()
+}
+
+// ClearMaintenance restores normal rendering
+func (r *Renderer) ClearMaintenance() {
+	r.SetMaintenance("")
+}
+
+// markUsed records a rendered template name with Options.TrackUsage
+func (r *Renderer) markUsed(name string) {
+	if !r.options.TrackUsage {
+		return
+	}
+
+	r.usedMu.Lock()
+	if r.used == nil {
+		r.used = map[string]bool{}
+	}
+	r.used[name] = true
+	r.usedMu.Unlock()
+}
+
+// UnusedTemplates returns sorted names of loaded templates which were not rendered since the start
+// (Options.TrackUsage has to be on). {{ define }} blocks are only tracked when rendered with macro.
+func (r *Renderer) UnusedTemplates() []string {
+	t, text := r.templates()
+
+	r.usedMu.Lock()
+	defer r.usedMu.Unlock()
+
+	var names []string
+	for _, tmpl := range t.Templates() {
+		if tmpl.Name() != t.Name() && !r.used[tmpl.Name()] {
+			names = append(names, tmpl.Name())
+		}
+	}
+	for _, tmpl := range text.Templates() {
+		if tmpl.Name() != text.Name() && !r.used[tmpl.Name()] {
+			names = append(names, tmpl.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// SetCachedFragment stores pre-rendered html returned by {{ cached key }} in templates of all copies
+func (r *Renderer) SetCachedFragment(key string, html template.HTML) {
+	r.fragmentsMu.Lock()
+	if r.fragments == nil {
+		r.fragments = map[string]template.HTML{}
+	}
+	r.fragments[key] = html
+	r.fragmentsMu.Unlock()
+}
+
+// Render HTML with layout support
+func (tmpl *TemplateCopy) HTML(name string, binding interface{}) (*bytes.Buffer, error) {
+	return tmpl.RenderFormat("html", name, binding)
+}
+
+// HTMLContext is HTML which gives up once ctx is done, see RenderFormatContext
+func (tmpl *TemplateCopy) HTMLContext(ctx context.Context, name string, binding interface{}) (*bytes.Buffer, error) {
+	return tmpl.RenderFormatContext(ctx, "html", name, binding)
+}
+
+// Write HTML to ResponseWriter
+func (tmpl *TemplateCopy) WriteHTML(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	tmpl.WriteHTMLType(rw, status, name, binding, "")
+}
+
+// Write HTML to ResponseWriter with contentType (e.g. "application/xhtml+xml"), "" means ContentHTML
+func (tmpl *TemplateCopy) WriteHTMLType(rw http.ResponseWriter, status int, name string, binding interface{}, contentType string) {
+	html, err := tmpl.HTML(name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	if contentType == "" {
+		contentType = ContentHTML
+	}
+
+	tmpl.write(rw, status, contentType, html)
+}
+
+// Write HTML rendered with the context of r, so the render stops when the client goes away
+func (tmpl *TemplateCopy) WriteHTMLContext(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	html, err := tmpl.HTMLContext(r.Context(), name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentHTML, html)
+}
+
+// Write HTML without layout for fragment requests (see IsFragmentRequest), with layout otherwise
+func (tmpl *TemplateCopy) WriteHTMLAuto(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	rw.Header().Add("Vary", "HX-Request, Turbo-Frame")
+
+	if IsFragmentRequest(r) {
+		tmpl.WriteFragment(rw, status, name, binding)
+		return
+	}
+
+	tmpl.WriteHTML(rw, status, name, binding)
+}
+
+// IsFragmentRequest reports whether r swaps a part of the page: htmx requests (HX-Request header)
+// except boosted ones which swap the whole body, and Turbo Frame requests (Turbo-Frame header)
+func IsFragmentRequest(r *http.Request) bool {
+	if r.Header.Get("Turbo-Frame") != "" {
+		return true
+	}
+
+	return r.Header.Get("HX-Request") != "" && r.Header.Get("HX-Boosted") == ""
+}
+
+// Render HTML without layout and Options.HTMLPreamble, e.g. for htmx or Turbo Frame swaps
+func (tmpl *TemplateCopy) Fragment(name string, binding interface{}) (*bytes.Buffer, error) {
+	ctx := context.WithValue(context.Background(), layoutOverrideKey{}, layoutOverride{fragment: true})
+
+	return tmpl.RenderFormatContext(ctx, "html", name, binding)
+}
+
+// Render HTML with layout instead of the layout of the copy for this render only, "" renders without layout.
+// Partials of the render can't pick another layout with useLayout.
+func (tmpl *TemplateCopy) HTMLWithLayout(layout, name string, binding interface{}) (*bytes.Buffer, error) {
+	ctx := context.WithValue(context.Background(), layoutOverrideKey{}, layoutOverride{layout: layout})
+
+	return tmpl.RenderFormatContext(ctx, "html", name, binding)
+}
+
+// Write HTML with layout instead of the layout of the copy to ResponseWriter, see HTMLWithLayout
+func (tmpl *TemplateCopy) WriteHTMLWithLayout(rw http.ResponseWriter, status int, layout, name string, binding interface{}) {
+	html, err := tmpl.HTMLWithLayout(layout, name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentHTML, html)
+}
+
+// Write HTML without layout to ResponseWriter
+func (tmpl *TemplateCopy) WriteFragment(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	html, err := tmpl.Fragment(name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentHTML, html)
+}
+
+// WriteHTMLSafe is WriteHTML: the page is rendered into a buffer first, so the client gets either
+// the complete page with status or a clean 500 error without any part of the page
+func (tmpl *TemplateCopy) WriteHTMLSafe(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	tmpl.WriteHTML(rw, status, name, binding)
+}
+
+// WriteHTMLStream executes the template straight into rw to send the first bytes sooner and save memory.
+// The status is sent before rendering, so an error in the middle leaves the client with status and
+// a truncated page - the error is only returned to be logged. Renders RenderTo buffers (no layout,
+// post-processing, Options.LayoutRegistry, SecondPass, TemplateTimeouts, ...) are written like WriteHTML.
+// Missing templates and layouts, and layouts without {{ yield }}, are written as error page and returned.
+func (tmpl *TemplateCopy) WriteHTMLStream(rw http.ResponseWriter, status int, name string, binding interface{}) error {
+	_, resolved := tmpl.resolve("html", name)
+	fullName := tmpl.localize(resolved + ".html")
+
+	if !tmpl.exists(fullName) || tmpl.buffered("html", resolved, fullName) {
+		html, err := tmpl.HTML(name, binding)
+		if err != nil {
+			tmpl.writeError(rw, err)
+			return err
+		}
+
+		tmpl.write(rw, status, ContentHTML, html)
+		return nil
+	}
+
+	tmpl.begin()
+	defer tmpl.renderMu.Unlock()
+	defer func(layout string) { tmpl.layout = layout }(tmpl.layout)
+
+	tmpl.renderer.markUsed(fullName)
+
+	if err := tmpl.streamableLayout(fullName); err != nil {
+		tmpl.writeError(rw, err)
+		return err
+	}
+
+	yielded := false
+	tmpl.setYield(fullName, binding, &yielded)
+	tmpl.renderer.markUsed(tmpl.layout + ".html")
+	tmpl.withLayout = true
+
+	layout, err := tmpl.extendLayouts(tmpl.layout+".html", fullName, binding)
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return err
+	}
+
+	rw.Header().Set(ContentType, ContentHTML)
+	rw.WriteHeader(status)
+
+	if err := tmpl.t.ExecuteTemplate(rw, layout, binding); err != nil {
+		return err
+	}
+
+	// a yield skipped by a condition of the layout is only found after the response was sent
+	if !yielded {
+		_, err := errNoYield(layout, fullName)
+		return err
+	}
+
+	return nil
+}
+
+// streamableLayout returns the errors a render of name with the layout of the copy would have before anything
+// is written: a missing layout and layouts of its {{ extends }} chain which never call {{ yield }}
+func (tmpl *TemplateCopy) streamableLayout(name string) error {
+	if err := tmpl.layoutExists(); err != nil {
+		return err
+	}
+
+	layout := tmpl.layout + ".html"
+	for depth := 0; layout != "" && depth <= maxLayoutChain; depth++ {
+		if tmpl.t.Lookup(layout) != nil && !tmpl.callsYield(layout, map[string]bool{}) {
+			_, err := errNoYield(layout, name)
+			return err
+		}
+
+		parent, err := tmpl.parentLayout(layout)
+		if err != nil {
+			return err
+		}
+		layout = parent
+	}
+
+	return nil
+}
+
+// callsYield reports whether template name has a {{ yield }} of the content (named yields don't count),
+// following the templates it includes with {{ template }}
+func (tmpl *TemplateCopy) callsYield(name string, seen map[string]bool) bool {
+	t := tmpl.t.Lookup(name)
+	if t == nil || t.Tree == nil || seen[name] {
+		return false
+	}
+	seen[name] = true
+
+	found := false
+	walkTree(t.Tree.Root, func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.CommandNode:
+			if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "yield" && len(n.Args) == 1 {
+				found = true
+			}
+		case *parse.TemplateNode:
+			if !found && tmpl.callsYield(n.Name, seen) {
+				found = true
+			}
+		}
+	})
+
+	return found
+}
+
+// Write HTML gzipped if the client accepts it and the body has at least Options.GzipMinBytes
+func (tmpl *TemplateCopy) WriteHTMLGzip(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	html, err := tmpl.HTML(name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	rw.Header().Add("Vary", "Accept-Encoding")
+
+	if html.Len() < tmpl.options.GzipMinBytes || !acceptsGzip(r) {
+		tmpl.writeBody(rw, status, ContentHTML, html)
+		return
+	}
+
+	rw.Header().Set("Content-Encoding", "gzip")
+	tmpl.writeBody(rw, status, ContentHTML, gzipBuffer(html))
+}
+
+// gzipBuffer returns gzipped buf from the pool and releases buf
+func gzipBuffer(buf *bytes.Buffer) *bytes.Buffer {
+	gz := getBuffer()
+	zw := gzip.NewWriter(gz)
+	zw.Write(buf.Bytes())
+	zw.Close()
+	ReleaseBuffer(buf)
+
+	return gz
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+
+		// "gzip;q=0" disables it
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Write HTML with a strong ETag of the body. GET and HEAD requests with a matching If-None-Match
+// get 304 Not Modified without body. Only 200 responses are tagged, the body is never compressed.
+func (tmpl *TemplateCopy) WriteHTMLCached(rw http.ResponseWriter, r *http.Request, status int, name string, binding interface{}) {
+	html, err := tmpl.HTML(name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	if status != http.StatusOK || tmpl.maintenance != "" {
+		tmpl.writeBody(rw, status, ContentHTML, html)
+		return
+	}
+
+	etag := ETag(html.Bytes())
+	rw.Header().Set("ETag", etag)
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		ReleaseBuffer(html)
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	tmpl.writeBody(rw, status, ContentHTML, html)
+}
+
+// ETag returns a strong ETag of body: a quoted hex SHA-256 prefix
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag, W/ tags compare weakly
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Shortcut for RenderFormat("js", ...) - render Javascript file
+func (tmpl *TemplateCopy) JS(name string, binding interface{}) (*bytes.Buffer, error) {
+	return tmpl.RenderFormat("js", name, binding)
+}
+
+// Write JS file to ResponseWriter
+func (tmpl *TemplateCopy) WriteJS(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	html, err := tmpl.RenderFormat("js", name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentJS, html)
+}
+
+// JSON encodes v with Options.JSONPrefix, JSONIndent and JSONUnescapeHTML
+func (tmpl *TemplateCopy) JSON(v interface{}) (*bytes.Buffer, error) {
+	buf := bytes.NewBufferString(tmpl.options.JSONPrefix)
+
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", tmpl.options.JSONIndent)
+	enc.SetEscapeHTML(!tmpl.options.JSONUnescapeHTML)
+
+	if err := enc.Encode(v); err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	return buf, nil
+}
+
+// Write v encoded as JSON to ResponseWriter, the maintenance page during maintenance
+func (tmpl *TemplateCopy) WriteJSON(rw http.ResponseWriter, status int, v interface{}) {
+	if tmpl.writeMaintenance(rw) {
+		return
+	}
+
+	buf, err := tmpl.JSON(v)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentJSON, buf)
+}
+
+// Shortcut for RenderFormat("txt", ...) - render plain text file with text/template, e.g. text emails
+func (tmpl *TemplateCopy) Text(name string, binding interface{}) (*bytes.Buffer, error) {
+	return tmpl.RenderFormat("txt", name, binding)
+}
+
+// Shortcut for RenderFormat("xml", ...) - render XML file, e.g. "sitemap.xml"
+func (tmpl *TemplateCopy) XML(name string, binding interface{}) (*bytes.Buffer, error) {
+	return tmpl.RenderFormat("xml", name, binding)
+}
+
+// Write XML file to ResponseWriter
+func (tmpl *TemplateCopy) WriteXML(rw http.ResponseWriter, status int, name string, binding interface{}) {
+	buf, err := tmpl.RenderFormat("xml", name, binding)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentXML, buf)
+}
+
+// XMLData encodes v with encoding/xml and Options.XMLIndent, prefixed with the <?xml ...?> header
+func (tmpl *TemplateCopy) XMLData(v interface{}) (*bytes.Buffer, error) {
+	buf := bytes.NewBufferString(xml.Header)
+
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", tmpl.options.XMLIndent)
+
+	if err := enc.Encode(v); err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	return buf, nil
+}
+
+// Write v encoded as XML to ResponseWriter, the maintenance page during maintenance
+func (tmpl *TemplateCopy) WriteXMLData(rw http.ResponseWriter, status int, v interface{}) {
+	if tmpl.writeMaintenance(rw) {
+		return
+	}
+
+	buf, err := tmpl.XMLData(v)
+
+	if err != nil {
+		tmpl.writeError(rw, err)
+		return
+	}
+
+	tmpl.write(rw, status, ContentXML, buf)
+}
+
+// writeError responds with Options.OnError, the development error page, the 500 error page or the status text
+func (tmpl *TemplateCopy) writeError(rw http.ResponseWriter, err error) {
+	if tmpl.options.OnError != nil {
+		tmpl.options.OnError(rw, err)
+		return
+	}
+
+	if tmpl.renderer.isDev() {
+		tmpl.writeDevError(rw, err)
+		return
+	}
+
+	if _, ok := tmpl.renderer.errorPage(http.StatusInternalServerError); ok {
+		tmpl.WriteError(rw, tmpl.request, http.StatusInternalServerError, err)
+		return
+	}
+
+	// template errors may show data of the binding
+	http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// write sends rendered template to ResponseWriter, gzipped with Options.Compress.
+// Maintenance page is always 503 HTML.
+func (tmpl *TemplateCopy) write(rw http.ResponseWriter, status int, contentType string, buf *bytes.Buffer) {
+	if tmpl.options.Compress && tmpl.request != nil {
+		rw.Header().Add("Vary", "Accept-Encoding")
+
+		if buf.Len() >= tmpl.options.GzipMinBytes && acceptsGzip(tmpl.request) {
+			rw.Header().Set("Content-Encoding", "gzip")
+			buf = gzipBuffer(buf)
+		}
+	}
+
+	tmpl.writeBody(rw, status, contentType, buf)
+}
+
+// writeMaintenance writes the maintenance page for helpers which encode data instead of rendering a template,
+// false out of maintenance
+func (tmpl *TemplateCopy) writeMaintenance(rw http.ResponseWriter) bool {
+	if tmpl.maintenance == "" {
+		return false
+	}
+
+	tmpl.WriteHTML(rw, http.StatusServiceUnavailable, tmpl.maintenance, nil)
+	return true
+}
+
+// writeBody sends buf as is and releases it
+func (tmpl *TemplateCopy) writeBody(rw http.ResponseWriter, status int, contentType string, buf *bytes.Buffer) {
+	if tmpl.maintenance != "" {
+		status = http.StatusServiceUnavailable
+		contentType = ContentHTML
+	}
+
+	rw.Header().Set(ContentType, contentType)
+	rw.WriteHeader(status)
+	rw.Write(buf.Bytes())
+
+	ReleaseBuffer(buf)
+}
+
+// General function to render template with "name.{format}" scheme
+func (tmpl *TemplateCopy) RenderFormat(format string, name string, binding interface{}) (*bytes.Buffer, error) {
+	return tmpl.RenderFormatContext(context.Background(), format, name, binding)
+}
+
+// RenderFormatContext is RenderFormat which stops rendering (partials and yield included) once ctx is done
+// and returns ctx.Err(), e.g. context.Canceled after the client disconnected. Template helpers which are
+// already running are not interrupted.
+func (tmpl *TemplateCopy) RenderFormatContext(ctx context.Context, format string, name string, binding interface{}) (*bytes.Buffer, error) {
+	format, name = tmpl.resolve(format, name)
+
+	if tmpl.options.RenderTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tmpl.options.RenderTimeout)
+		defer cancel()
+	}
+
+	var buf *bytes.Buffer
+	var err error
+	if timeout, ok := tmpl.options.TemplateTimeouts[name+"."+format]; ok {
+		buf, err = tmpl.renderTimeout(ctx, timeout, format, name, binding)
+	} else {
+		buf, err = tmpl.render(ctx, format, name, binding)
+	}
+
+	return buf, tmpl.devError(name+"."+format, binding, err)
+}
+
+// resolve returns the format and name RenderFormat renders for "name.{format}": the maintenance page
+// or the template of the theme if it has one
+func (tmpl *TemplateCopy) resolve(format, name string) (string, string) {
+	if tmpl.maintenance != "" {
+		return "html", tmpl.maintenance
+	}
+	if tmpl.theme != "" && tmpl.exists(tmpl.theme+"/"+name+"."+format) {
+		return format, tmpl.theme + "/" + name
+	}
+
+	return format, name
+}
+
+// renderable reports whether RenderFormat finds a template for "name.{format}",
+// localized or of Options.Engines, without asking Options.OnMissingTemplate
+func (tmpl *TemplateCopy) renderable(format, name string) bool {
+	format, name = tmpl.resolve(format, name)
+	fullName := tmpl.localize(name + "." + format)
+
+	return tmpl.engineOf(fullName) != nil || tmpl.exists(fullName)
+}
+
+// render executes and post-processes "name.{format}" template
+func (tmpl *TemplateCopy) render(ctx context.Context, format string, name string, binding interface{}) (*bytes.Buffer, error) {
+	tmpl.begin()
+	defer tmpl.renderMu.Unlock()
+
+	// contexts which are never done (Background) cost nothing
+	if ctx.Done() != nil {
+		tmpl.ctx = ctx
+	}
+
+	if override, ok := ctx.Value(layoutOverrideKey{}).(layoutOverride); ok {
+		defer func(layout string) { tmpl.layout, tmpl.fixedLayout, tmpl.fragment = layout, false, false }(tmpl.layout)
+		tmpl.layout, tmpl.fixedLayout, tmpl.fragment = override.layout, true, override.fragment
+	}
+
+	buf, err := tmpl.execute(format, name, binding)
+	if err != nil && ctx.Err() != nil {
+		return buf, ctx.Err()
+	}
+	if err != nil {
+		return buf, err
+	}
+
+	buf, err = tmpl.postProcess(format, buf)
+	if err != nil || format != "html" || tmpl.withLayout || tmpl.fragment {
+		return buf, err
+	}
+
+	return addPreamble(buf, tmpl.options.HTMLPreamble), nil
+}
+
+// layoutOverride is the layout of one render passed in its context by HTMLWithLayout and Fragment
+type layoutOverride struct {
+	layout   string
+	fragment bool
+}
+
+type layoutOverrideKey struct{}
+
+// renderTimeout renders in a goroutine and gives up after timeout.
+// The copy must not be used after a timeout since the render may still be running, error pages of write
+// helpers are rendered with a new copy.
+func (tmpl *TemplateCopy) renderTimeout(ctx context.Context, timeout time.Duration, format string, name string, binding interface{}) (*bytes.Buffer, error) {
+	type result struct {
+		buf *bytes.Buffer
+		err error
+	}
+
+	// the abandoned render stops at its next write
+	renderCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan result, 1)
+	go func() {
+		buf, err := tmpl.render(renderCtx, format, name, binding)
+		done <- result{buf, err}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+		if res.err == nil || renderCtx.Err() == nil {
+			return res.buf, res.err
+		}
+	case <-renderCtx.Done():
+	}
+
+	tmpl.abandoned = true
+
+	if ctx.Err() != nil {
+		return new(bytes.Buffer), ctx.Err()
+	}
+
+	err := fmt.Errorf("wutrender: rendering %q timed out after %v: %w", name+"."+format, timeout, context.DeadlineExceeded)
+	return new(bytes.Buffer), err
+}
+
+// execute renders "name.{format}" template (with layout) without post-processing
+func (tmpl *TemplateCopy) execute(format string, name string, binding interface{}) (*bytes.Buffer, error) {
+	fullName := tmpl.localize(name + "." + format)
+	tmpl.renderer.markUsed(fullName)
+
+	if engine := tmpl.engineOf(fullName); engine != nil {
+		return tmpl.executeEngine(engine, format, fullName, binding)
+	}
+
+	if !tmpl.exists(fullName) {
+		if tmpl.options.OnMissingTemplate != nil {
+			buf, err := tmpl.options.OnMissingTemplate(name, format)
+			if !errors.Is(err, ErrTemplateNotFound) {
+				return buf, err
+			}
+		}
+
+		err := notFound(fullName)
+		return new(bytes.Buffer), err
+	}
+
+	if layout := tmpl.options.layoutOf(format); format != "html" && layout != "" {
+		return tmpl.executeFormatLayout(format, layout+"."+format, fullName, binding, func() (*bytes.Buffer, error) {
+			return tmpl.executorOf(format)(fullName, binding)
+		})
+	}
+
+	if tmpl.options.isTextFormat(format) {
+		tmpl.format = format
+		return tmpl.executeTextTemplate(fullName, binding)
+	}
+
+	if format == "html" && (tmpl.options.SecondPass != nil || tmpl.options.LayoutRegistry != nil) {
+		return tmpl.renderContentFirst(fullName, binding)
+	}
+
+	if format == "html" && tmpl.layout == "" {
+		buf, err := tmpl.executeTemplate(fullName, binding)
+		if err != nil {
+			return buf, err
+		}
+
+		return contentWithoutBlocks(buf)
+	}
+
+	// Set yield function (layout)
+	if format == "html" && tmpl.layout != "" {
+		if engine := tmpl.engineOf(tmpl.layout + ".html"); engine != nil {
+			buf, err := tmpl.executeTemplate(fullName, binding)
+			if err != nil {
+				return buf, err
+			}
+			if buf, err = contentWithoutBlocks(buf); err != nil {
+				return buf, err
+			}
+
+			return tmpl.executeEngineLayout(engine, buf, binding)
+		}
+
+		if err := tmpl.layoutExists(); err != nil {
+			return new(bytes.Buffer), err
+		}
+
+		yielded := false
+		tmpl.setYield(fullName, binding, &yielded)
+		tmpl.renderer.markUsed(tmpl.layout + ".html")
+		tmpl.withLayout = true
+
+		layout, err := tmpl.extendLayouts(tmpl.layout+".html", fullName, binding)
+		if err != nil {
+			return new(bytes.Buffer), err
+		}
+
+		buf, err := tmpl.executeTemplate(layout, binding)
+		if err == nil && !yielded && tmpl.exists(fullName) {
+			return errNoYield(layout, fullName)
+		}
+
+		return buf, err
+	}
+
+	return tmpl.executeTemplate(fullName, binding)
+}
+
+// executeFormatLayout executes layout ("layouts/feed.xml") of a format other than html with yield returning
+// the content rendered by content, name is the content for errors
+func (tmpl *TemplateCopy) executeFormatLayout(format, layout, name string, binding interface{}, content func() (*bytes.Buffer, error)) (*bytes.Buffer, error) {
+	if !tmpl.exists(layout) {
+		return new(bytes.Buffer), fmt.Errorf("%w: %q", ErrLayoutNotFound, layout)
+	}
+
+	yielded := false
+	tmpl.yield = yieldFunc(func() (template.HTML, map[string][]byte, error) {
+		buf, err := content()
+		if err != nil {
+			return template.HTML(buf.String()), nil, err
+		}
+		defer ReleaseBuffer(buf)
+
+		main, sections, err := splitContent(buf.Bytes())
+
+		return template.HTML(main), sections, err
+	}, &yielded)
+	tmpl.renderer.markUsed(layout)
+	tmpl.withLayout = true
+
+	buf, err := tmpl.executorOf(format)(layout, binding)
+	if err == nil && !yielded {
+		return errNoYield(layout, name)
+	}
+
+	return buf, err
+}
+
+// executorOf returns executeTextTemplate for text formats, executeTemplate for the others
+func (tmpl *TemplateCopy) executorOf(format string) func(name string, binding interface{}) (*bytes.Buffer, error) {
+	if tmpl.options.isTextFormat(format) {
+		tmpl.format = format
+		return tmpl.executeTextTemplate
+	}
+
+	return tmpl.executeTemplate
+}
+
+// layoutExists returns ErrLayoutNotFound error if the layout template is missing
+func (tmpl *TemplateCopy) layoutExists() error {
+	if !tmpl.exists(tmpl.layout + ".html") {
+		return fmt.Errorf("%w: %q", ErrLayoutNotFound, tmpl.layout+".html")
+	}
+
+	return nil
+}
+
+// errNoYield reports a layout which dropped the content of name by not calling yield
+func errNoYield(layout, name string) (*bytes.Buffer, error) {
+	err := fmt.Errorf("wutrender: layout %q did not yield content of %q", layout, name)
+
+	return new(bytes.Buffer), err
+}
+
+// maxLayoutChain limits {{ extends }} chains, deeper chains are most likely a cycle
+const maxLayoutChain = 16
+
+// parentLayout returns "layouts/base.html" of a layout which starts with {{ extends "layouts/base" }}, "" without extends
+func (tmpl *TemplateCopy) parentLayout(layout string) (string, error) {
+	t := tmpl.t.Lookup(layout)
+	if t == nil || t.Tree == nil {
+		return "", nil
+	}
+
+	var parent string
+	var err error
+	walkTree(t.Tree.Root, func(node parse.Node) {
+		n, ok := node.(*parse.CommandNode)
+		if !ok || parent != "" || err != nil {
+			return
+		}
+		if ident, ok := n.Args[0].(*parse.IdentifierNode); !ok || ident.Ident != "extends" {
+			return
+		}
+
+		var name *parse.StringNode
+		if len(n.Args) > 1 {
+			name, _ = n.Args[1].(*parse.StringNode)
+		}
+		if name == nil {
+			err = fmt.Errorf("wutrender: layout %q: extends expects a literal layout name: %s", layout, n)
+			return
+		}
+
+		parent = name.Text + ".html"
+	})
+
+	return parent, err
+}
+
+// extendLayouts wraps yield of the current render into the layouts layout extends ({{ extends "layouts/base" }}),
+// and returns the outermost layout to execute. Each parent layout yields its child layout rendered with the content,
+// named yields return contentFor blocks of the child layout and fall back to the blocks of the content.
+// name is the content for errors, "" if the layout is rendered without content.
+func (tmpl *TemplateCopy) extendLayouts(layout, name string, binding interface{}) (string, error) {
+	start := layout
+
+	for depth := 0; ; depth++ {
+		parent, err := tmpl.parentLayout(layout)
+		if err != nil || parent == "" {
+			return layout, err
+		}
+
+		if depth == maxLayoutChain {
+			return "", fmt.Errorf("wutrender: layout %q extends more than %d layouts, is there a cycle?", start, maxLayoutChain)
+		}
+		if !tmpl.exists(parent) {
+			return "", fmt.Errorf("%w: %q extended by %q", ErrLayoutNotFound, parent, layout)
+		}
+
+		tmpl.renderer.markUsed(parent)
+
+		child, inner := layout, tmpl.yield
+		var sections map[string][]byte
+
+		called := false
+		tmpl.yield = yieldFunc(func() (template.HTML, map[string][]byte, error) {
+			outer := tmpl.yield
+			childCalled := false
+			tmpl.yield = func(section ...string) (template.HTML, error) {
+				if inner == nil {
+					return "", fmt.Errorf("yield called without layout")
+				}
+				if len(section) == 0 {
+					childCalled = true
+				}
+
+				return inner(section...)
+			}
+
+			buf, err := tmpl.executeTemplate(child, binding)
+			tmpl.yield = outer
+			if err != nil {
+				return template.HTML(buf.String()), nil, err
+			}
+			defer ReleaseBuffer(buf)
+
+			if name != "" && !childCalled {
+				_, err := errNoYield(child, name)
+				return "", nil, err
+			}
+
+			main, blocks, err := splitContent(buf.Bytes())
+			sections = blocks
+
+			return template.HTML(main), blocks, err
+		}, &called)
+
+		// named yields of the child layout fall back to the content blocks
+		wrapped := tmpl.yield
+		tmpl.yield = func(section ...string) (template.HTML, error) {
+			html, err := wrapped(section...)
+			if err != nil || len(section) != 1 || inner == nil {
+				return html, err
+			}
+			if _, ok := sections[section[0]]; ok {
+				return html, nil
+			}
+
+			return inner(section...)
+		}
+
+		layout = parent
+	}
+}
+
+// RenderBlocks renders blocks of "name.html" ({{ block "cart" . }} or {{ template "cart" . }} calls of the template)
+// one by one with binding and without layout, e.g. to re-hydrate parts of a page on the client
+func (tmpl *TemplateCopy) RenderBlocks(name string, binding interface{}, blocks []string) (map[string]*bytes.Buffer, error) {
+	page := tmpl.t.Lookup(name + ".html")
+	if page == nil || page.Tree == nil {
+		return nil, notFound(name + ".html")
+	}
+
+	used := map[string]bool{}
+	walkTree(page.Tree.Root, func(node parse.Node) {
+		if n, ok := node.(*parse.TemplateNode); ok {
+			used[n.Name] = true
+		}
+	})
+
+	tmpl.begin()
+	defer tmpl.renderMu.Unlock()
+
+	rendered := make(map[string]*bytes.Buffer, len(blocks))
+	for _, block := range blocks {
+		if !used[block] {
+			return nil, fmt.Errorf("wutrender: template %q has no block %q", name+".html", block)
+		}
+
+		buf, err := tmpl.executeTemplate(block, binding)
+		if err == nil {
+			buf, err = tmpl.postProcess("html", buf)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rendered[block] = buf
+	}
+
+	return rendered, nil
+}
+
+// RenderLayout renders "name.html" layout without content, so yield returns ""
+// (or the "yield called without layout" error with Options.StrictYield)
+func (tmpl *TemplateCopy) RenderLayout(name string, binding interface{}) (*bytes.Buffer, error) {
+	if tmpl.maintenance != "" {
+		return tmpl.RenderFormat("html", name, binding)
+	}
+
+	tmpl.begin()
+	defer tmpl.renderMu.Unlock()
+
+	if !tmpl.options.StrictYield {
+		tmpl.yield = func(section ...string) (template.HTML, error) {
+			return "", nil
+		}
+	}
+
+	tmpl.renderer.markUsed(name + ".html")
+
+	layout, err := tmpl.extendLayouts(name+".html", "", binding)
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	buf, err := tmpl.executeTemplate(layout, binding)
+	if err != nil {
+		return buf, err
+	}
+
+	return tmpl.postProcess("html", buf)
+}
+
+// addHelpers installs the helpers of the copy once, they read the per-render state of the copy
+// (yield, format) instead of being replaced by every render
+func (tmpl *TemplateCopy) addHelpers() {
+	// Add partial support
+	addPartial(tmpl)
+	addCriticalCSS(tmpl)
+	addUseLayout(tmpl)
+	addYield(tmpl)
+	addEscaper(tmpl)
+	addTranslate(tmpl)
+}
+
+// begin locks the copy for a render and resets the per-render state, callers unlock renderMu when done
+func (tmpl *TemplateCopy) begin() {
+	tmpl.renderMu.Lock()
+
+	tmpl.yield = nil
+	tmpl.format = ""
+	tmpl.withLayout = false
+	tmpl.ctx = nil
+}
+
+// renderContentFirst renders content before the layout, so the content can pick the layout (useLayout)
+// and the layout can get the SecondPass binding
+func (tmpl *TemplateCopy) renderContentFirst(name string, binding interface{}) (*bytes.Buffer, error) {
+	// useLayout picks the layout of this render only
+	defer func(layout string) { tmpl.layout = layout }(tmpl.layout)
+
+	content, err := tmpl.executeTemplate(name, binding)
+	if err != nil {
+		return content, err
+	}
+	if tmpl.layout == "" {
+		return contentWithoutBlocks(content)
+	}
+	defer ReleaseBuffer(content)
+
+	if err := tmpl.layoutExists(); err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	main, sections, err := splitContent(content.Bytes())
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	if tmpl.options.HeadingAnchors {
+		main = HeadingAnchors(main)
+	}
+
+	yielded := false
+	tmpl.yield = yieldFunc(func() (template.HTML, map[string][]byte, error) {
+		return template.HTML(main), sections, nil
+	}, &yielded)
+
+	if tmpl.options.SecondPass != nil {
+		binding = tmpl.options.SecondPass(main, binding)
+	}
+
+	tmpl.renderer.markUsed(tmpl.layout + ".html")
+	tmpl.withLayout = true
+
+	layout, err := tmpl.extendLayouts(tmpl.layout+".html", name, binding)
+	if err != nil {
+		return new(bytes.Buffer), err
+	}
+
+	buf, err := tmpl.executeTemplate(layout, binding)
+	if err == nil && !yielded {
+		return errNoYield(layout, name)
+	}
+
+	return buf, err
+}
+
+// RenderTo renders "name.{format}" straight into w instead of a buffer, e.g. for large pages.
+// Renders which need the whole output (post-processing options, Options.SecondPass, html without layout, ...)
+// or have a deadline (Options.RenderTimeout, TemplateTimeouts) are buffered as with RenderFormat.
+// On errors w may already have a part of the output.
+func (tmpl *TemplateCopy) RenderTo(w io.Writer, format, name string, binding interface{}) error {
+	if tmpl.theme != "" && tmpl.maintenance == "" && tmpl.exists(tmpl.theme+"/"+name+"."+format) {
+		name = tmpl.theme + "/" + name
+	}
+
+	fullName := tmpl.localize(name + "." + format)
+
+	if tmpl.buffered(format, name, fullName) {
+		buf, err := tmpl.RenderFormat(format, name, binding)
+		if err != nil {
+			return err
+		}
+
+		_, err = buf.WriteTo(w)
+		ReleaseBuffer(buf)
+
+		return err
+	}
+
+	if !tmpl.exists(fullName) {
+		return notFound(fullName)
+	}
+
+	tmpl.begin()
+	defer tmpl.renderMu.Unlock()
+
+	tmpl.renderer.markUsed(fullName)
+
+	if tmpl.options.isTextFormat(format) {
+		tmpl.format = format
+		return tmpl.text.ExecuteTemplate(w, fullName, binding)
+	}
+
+	// formats with a layout of Options.Layouts are buffered
+	if format != "html" {
+		return tmpl.t.ExecuteTemplate(w, fullName, binding)
+	}
+
+	if err := tmpl.layoutExists(); err != nil {
+		return err
+	}
+
+	yielded := false
+	tmpl.setYield(fullName, binding, &yielded)
+	tmpl.renderer.markUsed(tmpl.layout + ".html")
+	tmpl.withLayout = true
+
+	layout, err := tmpl.extendLayouts(tmpl.layout+".html", fullName, binding)
+	if err != nil {
+		return err
+	}
+
+	if err := tmpl.t.ExecuteTemplate(w, layout, binding); err != nil {
+		return err
+	}
+
+	if !yielded && tmpl.exists(fullName) {
+		_, err := errNoYield(layout, fullName)
+		return err
+	}
+
+	return nil
+}
+
+// buffered reports whether RenderTo of "name.{format}", localized to fullName, has to render into a buffer first
+func (tmpl *TemplateCopy) buffered(format, name, fullName string) bool {
+	opt := tmpl.options
+
+	if tmpl.maintenance != "" || opt.RenderTimeout > 0 || opt.TemplateTimeouts[name+"."+format] > 0 || opt.Minifiers[format] != nil || opt.EnsureTrailingNewline != nil {
+		return true
+	}
+
+	if tmpl.engineOf(fullName) != nil {
+		return true
+	}
+
+	if opt.OnMissingTemplate != nil && !tmpl.exists(fullName) {
+		return true
+	}
+
+	// layouts of other formats than html are rendered by executeFormatLayout
+	if format != "html" && opt.layoutOf(format) != "" {
+		return true
+	}
+
+	switch format {
+	case "go":
+		return opt.FormatGo
+	case "xml":
+		return true
+	case "html":
+		return tmpl.layout == "" || opt.SecondPass != nil || opt.LayoutRegistry != nil || opt.AbsoluteBaseURL != "" ||
+			opt.HeadingAnchors || opt.NormalizeHTML || tmpl.csrf != "" || tmpl.engineOf(tmpl.layout+".html") != nil
+	}
+
+	return false
+}
+
+// RenderPreferred renders the first of formats which has a "name.{format}" template
+// and returns the used format
+func (tmpl *TemplateCopy) RenderPreferred(formats []string, name string, binding interface{}) (string, *bytes.Buffer, error) {
+	for _, format := range formats {
+		if tmpl.renderable(format, name) {
+			buf, err := tmpl.RenderFormat(format, name, binding)
+			return format, buf, err
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: %q in formats %v", ErrTemplateNotFound, name, formats)
+}
+
+// StreamEach renders partialName for every item received from items and writes it to w as soon as it's rendered,
+// flushing w if it's http.Flusher. Returns when items is closed or, unless Options.StreamSkipErrors, on the first error.
+func (tmpl *TemplateCopy) StreamEach(w io.Writer, partialName string, items <-chan interface{}) error {
+	tmpl.begin()
+	defer tmpl.renderMu.Unlock()
+
+	flusher, _ := w.(http.Flusher)
+
+	for item := range items {
+		html, err := tmpl.renderPartial(partialName, item)
+		if err != nil {
+			if tmpl.options.StreamSkipErrors {
+				continue
+			}
+			return err
+		}
+
+		if _, err := io.WriteString(w, string(html)); err != nil {
+			return err
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return nil
+}
+
+// Renderer returns io.WriterTo which renders the template on WriteTo, not now.
+// It fails early with ErrTemplateNotFound only if RenderFormat wouldn't find the template
+// and there is no Options.OnMissingTemplate to ask on WriteTo.
+func (tmpl *TemplateCopy) Renderer(format, name string, binding interface{}) (io.WriterTo, error) {
+	if tmpl.options.OnMissingTemplate == nil && !tmpl.renderable(format, name) {
+		return nil, notFound(name + "." + format)
+	}
+
+	return &lazyRender{tmpl: tmpl, format: format, name: name, binding: binding}, nil
+}
+
+// lazyRender is the io.WriterTo returned by TemplateCopy.Renderer
+type lazyRender struct {
+	tmpl    *TemplateCopy
+	format  string
+	name    string
+	binding interface{}
+}
+
+func (l *lazyRender) WriteTo(w io.Writer) (int64, error) {
+	// Layouts are rendered into a buffer anyway
+	buf, err := l.tmpl.RenderFormat(l.format, l.name, l.binding)
+	if err != nil {
+		return 0, err
+	}
+	defer ReleaseBuffer(buf)
+
+	return buf.WriteTo(w)
+}
+
+// RenderTee renders "name.{format}" once and writes the output to both w and tee (e.g. an audit log).
+// Nothing is written if rendering fails.
+func (tmpl *TemplateCopy) RenderTee(w io.Writer, tee io.Writer, format, name string, binding interface{}) error {
+	buf, err := tmpl.RenderFormat(format, name, binding)
+	if err != nil {
+		return err
+	}
+
+	_, err = buf.WriteTo(io.MultiWriter(w, tee))
+	ReleaseBuffer(buf)
+
+	return err
+}
+
+// exists reports whether template with the "name.{format}" full name is loaded
+func (tmpl *TemplateCopy) exists(fullName string) bool {
+	if tmpl.options.isTextFormat(tmpl.options.formatOf(fullName)) {
+		return tmpl.text.Lookup(fullName) != nil
+	}
+
+	return tmpl.t.Lookup(fullName) != nil
+}
+
+// Override default layout
+func (tmpl *TemplateCopy) SetLayout(layout string) *TemplateCopy {
+	tmpl.renderMu.Lock()
+	tmpl.layout = layout
+	tmpl.renderMu.Unlock()
+
+	return tmpl
+}
+
+// SetTheme makes RenderFormat prefer "{theme}/{name}.{format}" templates over "{name}.{format}" for this copy
+func (tmpl *TemplateCopy) SetTheme(theme string) *TemplateCopy {
+	tmpl.theme = theme
+
+	return tmpl
+}
+
+// SetRequest sets the request the copy renders for, write helpers compress for it with Options.Compress
+func (tmpl *TemplateCopy) SetRequest(r *http.Request) *TemplateCopy {
+	tmpl.request = r
+
+	return tmpl
+}
+
+// SetRequestID sets the value returned by the requestID helper for this copy
+func (tmpl *TemplateCopy) SetRequestID(id string) *TemplateCopy {
+	return tmpl.SetFuncs(template.FuncMap{
+		"requestID": func() string {
+			return id
+		},
+	})
+}
+
+// SetLocal stores a request-scoped value which every template and partial of this copy reads with {{ local "key" }}
+func (tmpl *TemplateCopy) SetLocal(key string, val interface{}) *TemplateCopy {
+	if tmpl.locals == nil {
+		tmpl.locals = map[string]interface{}{}
+		tmpl.SetFuncs(template.FuncMap{
+			"local": func(key string) interface{} {
+				return tmpl.locals[key]
+			},
+		})
+	}
+	tmpl.locals[key] = val
+
+	return tmpl
+}
+
+// SetCSRF sets the CSRF token of this request. It's returned by the csrfToken helper
+// and injected as <meta name="csrf-token"> into <head> of html output.
+func (tmpl *TemplateCopy) SetCSRF(token string) *TemplateCopy {
+	tmpl.csrf = token
+
+	return tmpl.SetFuncs(template.FuncMap{
+		"csrfToken": func() string {
+			return token
+		},
+	})
+}
+
+// SetVariants sets A/B test variants of this request by experiment name for the variant helper:
+// {{ if eq (variant "homepage-hero") "video" }}. Unknown experiments return "" (control).
+func (tmpl *TemplateCopy) SetVariants(variants map[string]string) *TemplateCopy {
+	assigned := make(map[string]string, len(variants))
+	for experiment, variant := range variants {
+		assigned[experiment] = variant
+	}
+
+	return tmpl.SetFuncs(template.FuncMap{
+		"variant": func(experiment string) string {
+			return assigned[experiment]
+		},
+	})
+}
+
+// SetLocale overrides Options.Locale for this copy
+func (tmpl *TemplateCopy) SetLocale(locale string) *TemplateCopy {
+	tmpl.renderMu.Lock()
+	tmpl.locale = locale
+	tmpl.renderMu.Unlock()
+
+	return tmpl.SetFuncs(template.FuncMap{
+		"currency": currencyFunc(locale),
+	})
+}
+
+// Set template.FuncMap - it's safe and does not change source templates
+func (tmpl *TemplateCopy) SetFuncs(funcs template.FuncMap) *TemplateCopy {
+	tmpl.renderMu.Lock()
+	defer tmpl.renderMu.Unlock()
+
+	tmpl.t.Funcs(funcs)
+	tmpl.text.Funcs(texttemplate.FuncMap(funcs))
+
+	return tmpl
+}
+
+// Add yield keyword - calls yield of the current render, set by layout renders
+func addYield(tmpl *TemplateCopy) {
+	funcs := template.FuncMap{
+		"yield": func(section ...string) (template.HTML, error) {
+			if tmpl.yield == nil {
+				return "", fmt.Errorf("yield called without layout")
+			}
+
+			return tmpl.yield(section...)
+		},
+	}
+	tmpl.t.Funcs(funcs)
+	tmpl.text.Funcs(texttemplate.FuncMap(funcs))
+}
+
+// setYield makes yield of the current render return the rendered name template
+func (tmpl *TemplateCopy) setYield(name string, binding interface{}, called *bool) {
+	tmpl.yield = yieldFunc(func() (template.HTML, map[string][]byte, error) {
+		buf, err := tmpl.executeTemplate(name, binding)
+		if err != nil {
+			return template.HTML(buf.String()), nil, err
+		}
+		defer ReleaseBuffer(buf)
+
+		main, sections, err := splitContent(buf.Bytes())
+
+		return template.HTML(main), sections, err
+	}, called)
+}
+
+// yieldFunc returns yield helper of layouts: {{ yield }} returns the content, {{ yield "head" }} its contentFor "head" blocks.
+// Content is rendered by the first call, so layouts without named yields before {{ yield }} still render it lazily.
+func yieldFunc(render func() (template.HTML, map[string][]byte, error), called *bool) func(section ...string) (template.HTML, error) {
+	var main template.HTML
+	var sections map[string][]byte
+	var err error
+	rendered := false
+
+	return func(section ...string) (template.HTML, error) {
+		if len(section) > 1 {
+			return "", fmt.Errorf("wutrender: yield expects at most one section name, got %d", len(section))
+		}
+
+		if !rendered {
+			rendered = true
+			main, sections, err = render()
+		}
+		if err != nil {
+			return main, err
+		}
+
+		// return safe html here since we are rendering our own template
+		if len(section) == 1 {
+			return template.HTML(sections[section[0]]), nil
+		}
+
+		*called = true
+
+		return main, nil
+	}
+}
+
+// Add esc keyword - escapes with the Options.Escapers function of the rendered format (identity if there is none)
+func addEscaper(tmpl *TemplateCopy) {
+	funcs := texttemplate.FuncMap{
+		"esc": func(v interface{}) string {
+			if escape, ok := tmpl.options.Escapers[tmpl.format]; ok {
+				return escape(fmt.Sprint(v))
+			}
+
+			return fmt.Sprint(v)
+		},
+	}
+	tmpl.text.Funcs(funcs)
+}
+
+// Add useLayout keyword - select layout from Options.LayoutRegistry while rendering content
+func addUseLayout(tmpl *TemplateCopy) {
+	funcs := template.FuncMap{
+		"useLayout": func(key string) (string, error) {
+			layout, ok := tmpl.options.LayoutRegistry[key]
+			if !ok {
+				return "", fmt.Errorf("wutrender: layout %q is not in Options.LayoutRegistry", key)
+			}
+
+			// the caller picked the layout
+			if !tmpl.fixedLayout {
+				tmpl.layout = layout
+			}
+
+			return "", nil
+		},
+	}
+	tmpl.t.Funcs(funcs)
+}
+
+// Add partial keyword
+func addPartial(tmpl *TemplateCopy) {
+	funcs := template.FuncMap{
+		"partial": func(name string, pairs ...interface{}) (template.HTML, error) {
+			binding, err := mapFromPairs(pairs...)
+
+			if err != nil {
+				return "", err
+			}
+
+			return tmpl.renderPartial(name, binding)
+		},
+		// Same as partial, reads better when the result is passed as an argument (slot):
+		// {{ partial "cards/card" "body" (render "cards/body" .) }}
+		"render": func(name string, pairs ...interface{}) (template.HTML, error) {
+			binding, err := mapFromPairs(pairs...)
+
+			if err != nil {
+				return "", err
+			}
+
+			return tmpl.renderPartial(name, binding)
+		},
+		// Never inherits the parent binding - only the passed pairs are visible
+		"partialIsolated": func(name string, pairs ...interface{}) (template.HTML, error) {
+			if len(pairs)%2 != 0 {
+				return "", fmt.Errorf("wutrender: partialIsolated takes key-value pairs only, got %v", pairs)
+			}
+
+			binding, err := mapFromPairs(pairs...)
+
+			if err != nil {
+				return "", err
+			}
+
+			return tmpl.renderPartial(name, binding)
+		},
+		// Renders a text partial without escaping: {{ partialRaw "debug/payload" . }} renders "debug/_payload.txt"
+		"partialRaw": func(name string, pairs ...interface{}) (template.HTML, error) {
+			binding, err := mapFromPairs(pairs...)
+
+			if err != nil {
+				return "", err
+			}
+
+			return tmpl.renderRawPartial(name, binding)
+		},
+		// Renders a partial once per ttl ("5m" or time.Duration) and key: {{ cachedPartial "nav" "5m" "shared/nav" . }}
+		"cachedPartial": func(key string, ttl interface{}, name string, pairs ...interface{}) (template.HTML, error) {
+			binding, err := mapFromPairs(pairs...)
+
+			if err != nil {
+				return "", err
+			}
+
+			return tmpl.cachedPartial(key, ttl, name, binding)
+		},
+		"macro": func(name string, args ...interface{}) (template.HTML, error) {
+			params, ok := tmpl.options.Macros[name]
+			if !ok {
+				return "", fmt.Errorf("wutrender: macro %q is not in Options.Macros", name)
+			}
+
+			if len(args) != len(params) {
+				return "", fmt.Errorf("wutrender: macro %q takes %d arguments %v, got %d", name, len(params), params, len(args))
+			}
+
+			binding := make(map[string]interface{}, len(params))
+			for i, param := range params {
+				binding[param] = args[i]
+			}
+
+			return tmpl.renderNested(name, binding)
+		},
+	}
+	tmpl.t.Funcs(funcs)
+}
+
+// renderPartial renders "{filepath}/_{filename}.html" template
+func (tmpl *TemplateCopy) renderPartial(name string, binding interface{}) (template.HTML, error) {
+	if specs, ok := tmpl.options.PartialSchemas[name]; ok {
+		if err := validateProps(name, specs, binding); err != nil {
+			return "", err
+		}
+	}
+
+	dir, filename := filepath.Split(name)
+
+	html, err := tmpl.renderNested(tmpl.localize(dir+"_"+filename+".html"), binding)
+	if err == errMaxDepth {
+		err = fmt.Errorf("wutrender: partial %q exceeded max depth of %d", name, tmpl.options.MaxPartialDepth)
+	}
+
+	return html, err
+}
+
+// cachedPartial returns the partial cached by key until it expires, caching is skipped in development mode
+func (tmpl *TemplateCopy) cachedPartial(key string, ttl interface{}, name string, binding interface{}) (template.HTML, error) {
+	var d time.Duration
+	switch v := ttl.(type) {
+	case time.Duration:
+		d = v
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return "", fmt.Errorf("wutrender: cachedPartial %q has invalid ttl: %v", key, err)
+		}
+	default:
+		return "", fmt.Errorf("wutrender: cachedPartial %q ttl should be a string or time.Duration, got %T", key, ttl)
+	}
+
+	r := tmpl.renderer
+	store := r.cacheStore()
+
+	if !r.isDev() {
+		html, ok, err := store.Get(key)
+		if err != nil {
+			r.warnf("cachedPartial %q: %v", key, err)
+		}
+		if ok {
+			return html, nil
+		}
+	}
+
+	html, err := tmpl.renderPartial(name, binding)
+	if err != nil {
+		return html, err
+	}
+
+	if !r.isDev() {
+		if err := store.Set(key, html, d); err != nil {
+			r.warnf("cachedPartial %q: %v", key, err)
+		}
+	}
+
+	return html, nil
+}
+
+// renderRawPartial renders "{filepath}/_{filename}.txt" (or another text format given as extension) with the text engine
+func (tmpl *TemplateCopy) renderRawPartial(name string, binding interface{}) (template.HTML, error) {
+	dir, filename := filepath.Split(name)
+	fullName := dir + "_" + filename
+	format := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if format == "" || !tmpl.options.isTextFormat(format) {
+		format = "txt"
+		fullName += ".txt"
+	}
+
+	if tmpl.depth >= tmpl.options.MaxPartialDepth {
+		return "", fmt.Errorf("wutrender: partial %q exceeded max depth of %d", name, tmpl.options.MaxPartialDepth)
+	}
+
+	tmpl.depth++
+	defer func() { tmpl.depth-- }()
+
+	tmpl.renderer.markUsed(fullName)
+	defer func(format string) { tmpl.format = format }(tmpl.format)
+	tmpl.format = format
+	buf, err := tmpl.executeTextTemplate(fullName, binding)
+	if err != nil {
+		return template.HTML(buf.String()), err
+	}
+
+	// text output is inlined as is on purpose
+	html := template.HTML(buf.String())
+	ReleaseBuffer(buf)
+
+	return html, nil
+}
+
+// validateProps checks the partial binding against its Options.PartialSchemas entry
+func validateProps(name string, specs []PropSpec, binding interface{}) error {
+	props, ok := binding.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("wutrender: partial %q takes key-value props, got %T", name, binding)
+	}
+
+	for _, spec := range specs {
+		value, ok := props[spec.Name]
+		if !ok {
+			if spec.Required {
+				return fmt.Errorf("wutrender: partial %q is missing required prop %q", name, spec.Name)
+			}
+			continue
+		}
+
+		if spec.Type != "" && fmt.Sprintf("%T", value) != spec.Type {
+			return fmt.Errorf("wutrender: partial %q prop %q must be %s, got %T", name, spec.Name, spec.Type, value)
+		}
+	}
+
+	return nil
+}
+
+var errMaxDepth = errors.New("wutrender: max depth exceeded")
+
+// renderNested renders a template from inside of another one, limited by Options.MaxPartialDepth
+func (tmpl *TemplateCopy) renderNested(fullName string, binding interface{}) (template.HTML, error) {
+	if tmpl.depth >= tmpl.options.MaxPartialDepth {
+		return "", errMaxDepth
+	}
+
+	tmpl.depth++
+	defer func() { tmpl.depth-- }()
+
+	tmpl.renderer.markUsed(fullName)
+	buf, err := tmpl.executeTemplate(fullName, binding)
+	if err != nil {
+		return template.HTML(buf.String()), err
+	}
+
+	// return safe html
+	html := template.HTML(buf.String())
+	ReleaseBuffer(buf)
+
+	return html, nil
+}
+
+// Add criticalCSS keyword - inline "name.css" template into <style> tag
+func addCriticalCSS(tmpl *TemplateCopy) {
+	funcs := template.FuncMap{
+		"criticalCSS": func(name string) (template.HTML, error) {
+			css, err := tmpl.criticalCSS(name)
+			if err != nil {
+				return "", err
+			}
+
+			// return safe html here since we are rendering our own template
+			return template.HTML("<style>" + string(css) + "</style>"), nil
+		},
+	}
+	tmpl.t.Funcs(funcs)
+}
+
+// criticalCSS renders "name.css" with the text engine, cached per name in production
+func (tmpl *TemplateCopy) criticalCSS(name string) (template.CSS, error) {
+	r := tmpl.renderer
+
+	if !r.isDev() {
+		r.cssMu.RLock()
+		css, ok := r.css[name]
+		r.cssMu.RUnlock()
+
+		if ok {
+			return css, nil
+		}
+	}
+
+	r.markUsed(name + ".css")
+	buf, err := tmpl.executeTextTemplate(name+".css", nil)
+	if err != nil {
+		return "", err
+	}
+	css := template.CSS(buf.String())
+	ReleaseBuffer(buf)
+
+	if !r.isDev() {
+		r.cssMu.Lock()
+		r.css[name] = css
+		r.cssMu.Unlock()
+	}
+
+	return css, nil
+}
+
+// mapFromPairs converts interface parameters to a string map for partial binding
+func mapFromPairs(pairs ...interface{}) (interface{}, error) {
+	length := len(pairs)
+
+	if length == 1 {
+		return pairs[0], nil
+	}
+
+	if length%2 != 0 {
+		return nil, fmt.Errorf("wutrender: number of parameters must be multiple of 2, got %v", pairs)
+	}
+
+	m := make(map[string]interface{}, length/2)
+
+	for i := 0; i < length; i += 2 {
+		v := pairs[i]
+		switch v.(type) {
+		case string:
+			m[v.(string)] = pairs[i+1]
+		default:
+			return nil, fmt.Errorf("wutrender: pairs should be in format \"string => interface{}\", got %v, %v", v, pairs[i+1])
+		}
+	}
+	return m, nil
+}
+
+// executeTemplate renders name into a pooled buffer, it's aborted once the context of the render is done
+func (tmpl *TemplateCopy) executeTemplate(name string, binding interface{}) (*bytes.Buffer, error) {
+	if tmpl.ctx != nil && tmpl.ctx.Err() != nil {
+		return new(bytes.Buffer), tmpl.ctx.Err()
+	}
+
+	buf := getBuffer()
+	err := tmpl.t.ExecuteTemplate(tmpl.writer(buf), name, binding)
+
+	if err != nil {
+		ReleaseBuffer(buf)
+		return new(bytes.Buffer), err
 	}
 
 	return buf, nil
 }
+
+// executeTextTemplate is executeTemplate with the text engine
+func (tmpl *TemplateCopy) executeTextTemplate(name string, binding interface{}) (*bytes.Buffer, error) {
+	if tmpl.ctx != nil && tmpl.ctx.Err() != nil {
+		return new(bytes.Buffer), tmpl.ctx.Err()
+	}
+
+	buf := getBuffer()
+	err := tmpl.text.ExecuteTemplate(tmpl.writer(buf), name, binding)
+
+	if err != nil {
+		ReleaseBuffer(buf)
+		return new(bytes.Buffer), err
+	}
+
+	return buf, nil
+}
+
+// writer returns buf, failing writes once the context of the render is done
+func (tmpl *TemplateCopy) writer(buf *bytes.Buffer) io.Writer {
+	if tmpl.ctx == nil {
+		return buf
+	}
+
+	return ctxWriter{ctx: tmpl.ctx, w: buf}
+}
+
+// ctxWriter stops template execution at the first write after ctx is done
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return w.w.Write(p)
+}
+
+// Buffers of rendered templates, reused by renders after ReleaseBuffer
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Larger buffers are left to the GC instead of pinning their memory in the pool
+const maxPooledBuffer = 1 << 20
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return buf
+}
+
+// ReleaseBuffer returns a buffer of HTML, RenderFormat, ... to the pool for the following renders.
+// Neither buf nor its Bytes() may be used afterwards. Write helpers release their buffers themselves.
+func ReleaseBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// isTextFormat reports whether format is parsed with text/template
+func isTextFormat(format string) bool {
+	for _, v := range textFormats {
+		if v == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// layoutOf returns the layout of format from Layouts, Layout for html without an entry
+func (opt *Options) layoutOf(format string) string {
+	if layout, ok := opt.Layouts[format]; ok {
+		return layout
+	}
+	if format == "html" {
+		return opt.Layout
+	}
+
+	return ""
+}
+
+// isTextFormat extends the built-in text formats with TextFormats and the formats of Escapers
+func (opt *Options) isTextFormat(format string) bool {
+	if _, ok := opt.Escapers[format]; ok {
+		return true
+	}
+
+	for _, v := range opt.TextFormats {
+		if v == format {
+			return true
+		}
+	}
+
+	return isTextFormat(format)
+}
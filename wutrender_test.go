@@ -2,8 +2,26 @@ package wutrender
 
 import (
 	// "fmt"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"go/format"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func Test_NewRenderer(t *testing.T) {
@@ -11,7 +29,15 @@ func Test_NewRenderer(t *testing.T) {
 		Directory: "fixtures",
 	})
 
-	assert.Equal(t, len(r.t.Templates()), 3)
+	files := 0
+	filepath.Walk("fixtures", func(path string, info os.FileInfo, err error) error {
+		if filepath.Ext(path) == ".tmpl" {
+			files++
+		}
+		return nil
+	})
+
+	assert.Equal(t, r.UserTemplateCount(), files)
 }
 
 func Test_TemplateNames(t *testing.T) {
@@ -21,6 +47,19 @@ func Test_TemplateNames(t *testing.T) {
 
 	assert.NotEqual(t, r.t.Lookup("base/hello"), nil)
 	assert.Nil(t, r.t.Lookup("base/notemplate"))
+
+	assert.True(t, r.Exists("base/hello.html"))
+	assert.True(t, r.Exists("latex/doc.latex"))
+	assert.False(t, r.Exists("base/hello"))
+	assert.False(t, r.Exists("base/notemplate.html"))
+	assert.False(t, r.Exists("fixtures"))
+
+	names := r.TemplateNames()
+	assert.Equal(t, len(names), r.UserTemplateCount())
+	assert.Contains(t, names, "base/hello.html")
+	assert.NotContains(t, names, "fixtures")
+
+	assert.Equal(t, r.TemplateNames("latex", "go"), []string{"gen/model.go", "latex/doc.latex"})
 }
 
 func Test_HTML(t *testing.T) {
@@ -48,3 +87,1859 @@ func Test_LayoutHTML(t *testing.T) {
 	assert.Equal(t, html.String(), "head\n<div>Hello </div>\nfoot")
 	assert.Equal(t, htmlBind.String(), "head\n<div>Hello [willkommen]</div>\nfoot")
 }
+
+func Test_Layouts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "layouts"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "app.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "feed.xml.tmpl"), []byte(`<feed>{{ yield }}</feed>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "email.txt.tmpl"), []byte("Hi {{ .Name }},\n{{ yield }}\n-- & Co"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "broken.txt.tmpl"), []byte(`no content`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`<p>{{ .Name }}</p>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "posts.xml.tmpl"), []byte(`<entry>{{ .Name }}</entry>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "welcome.txt.tmpl"), []byte(`welcome <{{ .Name }}>`), 0644)
+
+	binding := map[string]string{"Name": "Bob"}
+
+	r := New(Options{
+		Directory: dir,
+		Layout:    "unused",
+		Layouts:   map[string]string{"html": "layouts/app", "xml": "layouts/feed", "txt": "layouts/email"},
+	})
+
+	html, err := r.Copy().HTML("page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><p>Bob</p></main>")
+
+	xml, err := r.Copy().XML("posts", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, xml.String(), "<feed><entry>Bob</entry></feed>")
+
+	txt, err := r.Copy().Text("welcome", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, txt.String(), "Hi Bob,\nwelcome <Bob>\n-- & Co")
+
+	var w strings.Builder
+	assert.Nil(t, r.Copy().RenderTo(&w, "txt", "welcome", binding))
+	assert.Equal(t, w.String(), "Hi Bob,\nwelcome <Bob>\n-- & Co")
+
+	w.Reset()
+	assert.Nil(t, r.Copy().RenderTo(&w, "html", "page", binding))
+	assert.Equal(t, w.String(), "<main><p>Bob</p></main>")
+
+	// SetLayout is the html layout
+	xml, err = r.Copy().SetLayout("").XML("posts", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, xml.String(), "<feed><entry>Bob</entry></feed>")
+
+	r = New(Options{
+		Directory: dir,
+		Layout:    "layouts/app",
+		Layouts:   map[string]string{"txt": "", "xml": "layouts/missing"},
+	})
+
+	html, err = r.Copy().HTML("page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><p>Bob</p></main>")
+
+	txt, err = r.Copy().Text("welcome", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, txt.String(), "welcome <Bob>")
+
+	_, err = r.Copy().XML("posts", binding)
+	assert.True(t, errors.Is(err, ErrLayoutNotFound))
+
+	w.Reset()
+	assert.Nil(t, r.Copy().RenderTo(&w, "txt", "welcome", binding))
+	assert.Equal(t, w.String(), "welcome <Bob>")
+
+	r = New(Options{Directory: dir, Layouts: map[string]string{"txt": "layouts/broken"}})
+	_, err = r.Copy().Text("welcome", binding)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "did not yield content")
+}
+
+func Test_FormatGo(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		FormatGo:  true,
+	})
+
+	binding := map[string]interface{}{
+		"Package": "models",
+		"Name":    "User",
+		"Fields": []map[string]string{
+			{"Name": "ID", "Type": "int64"},
+			{"Name": "Email", "Type": "string"},
+		},
+	}
+
+	src, err := r.Copy().RenderFormat("go", "gen/model", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, src.String(), "package models\n\ntype User struct {\n\tID    int64\n\tEmail string\n}\n")
+
+	formatted, _ := format.Source(src.Bytes())
+	assert.Equal(t, src.String(), string(formatted))
+
+	binding["Name"] = "1User"
+	_, err = r.Copy().RenderFormat("go", "gen/model", binding)
+	assert.NotNil(t, err)
+}
+
+func Test_WriteHTMLAuto(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLAuto(rw, req, 200, "base/hello", "htmx")
+
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	assert.Equal(t, rw.Body.String(), "head\n<div>Hello htmx</div>\nfoot")
+
+	req.Header.Set("HX-Request", "true")
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLAuto(rw, req, 200, "base/hello", "htmx")
+
+	assert.Equal(t, rw.Body.String(), "<div>Hello htmx</div>")
+	assert.Equal(t, rw.Header().Get("Vary"), "HX-Request, Turbo-Frame")
+
+	// boosted links swap the whole body
+	req.Header.Set("HX-Boosted", "true")
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLAuto(rw, req, 200, "base/hello", "htmx")
+
+	assert.Equal(t, rw.Body.String(), "head\n<div>Hello htmx</div>\nfoot")
+
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Set("Turbo-Frame", "hello")
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLAuto(rw, req, 200, "base/hello", "turbo")
+
+	assert.Equal(t, rw.Body.String(), "<div>Hello turbo</div>")
+}
+
+func Test_Fragment(t *testing.T) {
+	r := New(Options{
+		Directory:    "fixtures",
+		Layout:       "base/layout",
+		HTMLPreamble: "<!DOCTYPE html>\n",
+	})
+
+	tmpl := r.Copy()
+	html, err := tmpl.Fragment("base/hello", "fragment")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<div>Hello fragment</div>")
+
+	// the copy keeps its layout
+	html, err = tmpl.HTML("base/hello", "page")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head\n<div>Hello page</div>\nfoot")
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteFragment(rw, 201, "base/hello", "fragment")
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	assert.Equal(t, rw.Body.String(), "<div>Hello fragment</div>")
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteFragment(rw, 200, "base/missing", nil)
+	assert.Equal(t, rw.Code, 500)
+}
+
+func Test_JSON(t *testing.T) {
+	r := New(Options{Directory: "fixtures"})
+	v := map[string]interface{}{"name": "<b>bob</b>", "ids": []int{1, 2}}
+
+	buf, err := r.Copy().JSON(v)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `{"ids":[1,2],"name":"\u003cb\u003ebob\u003c/b\u003e"}`+"\n")
+
+	r = New(Options{
+		Directory:        "fixtures",
+		JSONIndent:       "  ",
+		JSONUnescapeHTML: true,
+		JSONPrefix:       ")]}',\n",
+	})
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteJSON(rw, 201, v)
+
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentJSON)
+	assert.Equal(t, rw.Body.String(), ")]}',\n{\n  \"ids\": [\n    1,\n    2\n  ],\n  \"name\": \"<b>bob</b>\"\n}\n")
+
+	_, err = r.Copy().JSON(func() {})
+	assert.NotNil(t, err)
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteJSON(rw, 200, make(chan int))
+	assert.Equal(t, rw.Code, 500)
+}
+
+func Test_OnError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "broken.html.tmpl"), []byte(`before {{ fail }} after`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "broken.js.tmpl"), []byte(`{{ fail }}`), 0644)
+
+	var logged []error
+	r := New(Options{
+		Directory: dir,
+		Funcs: []template.FuncMap{{"fail": func() (string, error) {
+			return "", errors.New("secret db password")
+		}}},
+		OnError: func(rw http.ResponseWriter, err error) {
+			logged = append(logged, err)
+			http.Error(rw, "Something went wrong", http.StatusInternalServerError)
+		},
+	})
+
+	// the error text never ends up in the buffer
+	buf, err := r.Copy().HTML("broken", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, buf.Len(), 0)
+
+	_, err = r.Copy().HTML("missing", nil)
+	assert.NotNil(t, err)
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "broken", nil)
+	assert.Equal(t, rw.Code, 500)
+	assert.Equal(t, rw.Body.String(), "Something went wrong\n")
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteJS(rw, 200, "broken", nil)
+	assert.Equal(t, rw.Code, 500)
+	assert.NotContains(t, rw.Body.String(), "secret")
+
+	assert.Equal(t, len(logged), 2)
+	assert.Contains(t, logged[0].Error(), "secret db password")
+}
+
+func Test_XML(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "sitemap.xml.tmpl"), []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset>{{ range . }}<url><loc>{{ . }}</loc></url>{{ end }}</urlset>`), 0644)
+
+	r := New(Options{Directory: dir, XMLIndent: "  "})
+
+	buf, err := r.Copy().XML("sitemap", []string{"https://example.com/?a=1&b=2"})
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `<?xml version="1.0" encoding="UTF-8"?>
+<urlset><url><loc>https://example.com/?a=1&amp;b=2</loc></url></urlset>`)
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteXML(rw, 200, "sitemap", nil)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentXML)
+
+	type item struct {
+		XMLName xml.Name `xml:"item"`
+		ID      int      `xml:"id,attr"`
+		Name    string   `xml:"name"`
+	}
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteXMLData(rw, 201, item{ID: 1, Name: "<bob>"})
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentXML)
+	assert.Equal(t, rw.Body.String(), xml.Header+"<item id=\"1\">\n  <name>&lt;bob&gt;</name>\n</item>")
+
+	_, err = r.Copy().XMLData(make(chan int))
+	assert.NotNil(t, err)
+}
+
+func Test_WriteHTMLSafeAndStream(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<body>{{ yield }}</body>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ .Title }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "broken.html.tmpl"), []byte(`before {{ fail }} after`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Layout:    "layout",
+		Funcs: []template.FuncMap{{"fail": func() (string, error) {
+			return "", errors.New("boom")
+		}}},
+	})
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLSafe(rw, 200, "broken", nil)
+	assert.Equal(t, rw.Code, 500)
+	assert.NotContains(t, rw.Body.String(), "before")
+	assert.NotContains(t, rw.Body.String(), "boom")
+
+	rw = httptest.NewRecorder()
+	err := r.Copy().WriteHTMLStream(rw, 200, "broken", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "boom")
+	assert.Equal(t, rw.Code, 200)
+	// the layout was sent up to yield
+	assert.Equal(t, rw.Body.String(), "<body>")
+
+	rw = httptest.NewRecorder()
+	err = r.Copy().WriteHTMLStream(rw, 201, "page", map[string]string{"Title": "<ok>"})
+	assert.Nil(t, err)
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	assert.Equal(t, rw.Body.String(), "<body>&lt;ok&gt;</body>")
+
+	// missing templates are a clean 500
+	rw = httptest.NewRecorder()
+	err = r.Copy().WriteHTMLStream(rw, 200, "missing", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+	assert.Equal(t, rw.Code, 500)
+}
+
+func Test_WriteHTMLStreamLayout(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`page`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "bare.html.tmpl"), []byte(`<body></body>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "child.html.tmpl"), []byte(`{{ extends "bare" }}<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "included.html.tmpl"), []byte(`{{ define "main" }}<main>{{ yield }}</main>{{ end }}<body>{{ template "main" . }}</body>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "hidden.html.tmpl"), []byte(`<body>{{ if .Show }}{{ yield }}{{ end }}</body>`), 0644)
+
+	r := New(Options{Directory: dir})
+
+	// checked before the status is sent
+	for _, layout := range []string{"missing", "bare", "child"} {
+		tmpl := r.Copy()
+		tmpl.SetLayout(layout)
+
+		rw := httptest.NewRecorder()
+		err := tmpl.WriteHTMLStream(rw, 200, "page", nil)
+		assert.NotNil(t, err, layout)
+		assert.Equal(t, rw.Code, 500, layout)
+		assert.NotContains(t, rw.Body.String(), "<body>", layout)
+	}
+
+	tmpl := r.Copy()
+	tmpl.SetLayout("missing")
+	err := tmpl.WriteHTMLStream(httptest.NewRecorder(), 200, "page", nil)
+	assert.True(t, errors.Is(err, ErrLayoutNotFound))
+
+	tmpl = r.Copy()
+	tmpl.SetLayout("included")
+	rw := httptest.NewRecorder()
+	err = tmpl.WriteHTMLStream(rw, 200, "page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, rw.Body.String(), "<body><main>page</main></body>")
+
+	// a yield skipped at runtime can only be returned
+	tmpl = r.Copy()
+	tmpl.SetLayout("hidden")
+	rw = httptest.NewRecorder()
+	err = tmpl.WriteHTMLStream(rw, 200, "page", map[string]bool{"Show": false})
+	assert.NotNil(t, err)
+	assert.Equal(t, rw.Code, 200)
+}
+
+func Test_WriteHTMLStreamLikeHTML(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "dark"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<head></head>P[{{ yield }}]`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "admin.html.tmpl"), []byte(`A[{{ yield }}]`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ useLayout "admin" }}page`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "other.html.tmpl"), []byte(`other`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "dark", "other.html.tmpl"), []byte(`dark`), 0644)
+
+	stream := func(tmpl *TemplateCopy, name string) string {
+		rw := httptest.NewRecorder()
+		assert.Nil(t, tmpl.WriteHTMLStream(rw, 200, name, nil))
+		return rw.Body.String()
+	}
+
+	r := New(Options{Directory: dir, Layout: "layout"})
+	assert.Equal(t, stream(r.Copy().SetTheme("dark"), "other"), "<head></head>P[dark]")
+	assert.Equal(t, stream(r.Copy().SetCSRF("t0k"), "other"), `<head><meta name="csrf-token" content="t0k"></head>P[other]`)
+
+	// the content picks the layout of this render only
+	r = New(Options{Directory: dir, Layout: "layout", LayoutRegistry: map[string]string{"admin": "admin"}})
+	tmpl := r.Copy()
+	assert.Equal(t, stream(tmpl, "page"), "A[page]")
+	assert.Equal(t, stream(tmpl, "other"), "<head></head>P[other]")
+
+	ioutil.WriteFile(filepath.Join(dir, "slow.html.tmpl"), []byte(`{{ sleep }}slow`), 0644)
+	r = New(Options{
+		Directory:        dir,
+		Layout:           "layout",
+		Funcs:            []template.FuncMap{{"sleep": func() string { time.Sleep(200 * time.Millisecond); return "" }}},
+		TemplateTimeouts: map[string]time.Duration{"slow.html": 10 * time.Millisecond},
+	})
+
+	rw := httptest.NewRecorder()
+	err := r.Copy().WriteHTMLStream(rw, 200, "slow", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, rw.Code, 500)
+}
+
+func Test_WriteHTMLGzip(t *testing.T) {
+	r := New(Options{
+		Directory:    "fixtures",
+		GzipMinBytes: 100,
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLGzip(rw, req, 200, "base/hello", "small")
+
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, rw.Header().Get("Vary"), "Accept-Encoding")
+	assert.Equal(t, rw.Body.String(), "<div>Hello small</div>")
+
+	large := strings.Repeat("large ", 50)
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLGzip(rw, req, 200, "base/hello", large)
+
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	zr, err := gzip.NewReader(rw.Body)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(zr)
+	assert.Equal(t, string(body), "<div>Hello "+large+"</div>")
+
+	// client without gzip support
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLGzip(rw, req, 200, "base/hello", large)
+
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, rw.Body.String(), "<div>Hello "+large+"</div>")
+}
+
+func Test_Compress(t *testing.T) {
+	r := New(Options{
+		Directory:    "fixtures",
+		GzipMinBytes: 100,
+		Compress:     true,
+	})
+	large := strings.Repeat("large ", 50)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+
+	rw := httptest.NewRecorder()
+	r.Copy().SetRequest(req).WriteHTML(rw, 200, "base/hello", large)
+
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, rw.Header().Get("Vary"), "Accept-Encoding")
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	zr, err := gzip.NewReader(rw.Body)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(zr)
+	assert.Equal(t, string(body), "<div>Hello "+large+"</div>")
+
+	rw = httptest.NewRecorder()
+	r.Copy().SetRequest(req).WriteHTML(rw, 200, "base/hello", "small")
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, rw.Header().Get("Vary"), "Accept-Encoding")
+	assert.Equal(t, rw.Body.String(), "<div>Hello small</div>")
+
+	// copies without request are sent as is
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "base/hello", large)
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, rw.Header().Get("Vary"), "")
+
+	req.Header.Set("Accept-Encoding", "br")
+	rw = httptest.NewRecorder()
+	r.Copy().SetRequest(req).WriteHTML(rw, 200, "base/hello", large)
+	assert.Equal(t, rw.Header().Get("Content-Encoding"), "")
+	assert.Equal(t, rw.Body.String(), "<div>Hello "+large+"</div>")
+}
+
+func Test_WriteHTMLCached(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLCached(rw, req, 200, "base/hello", "x")
+
+	etag := rw.Header().Get("ETag")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, etag, ETag([]byte("<div>Hello x</div>")))
+	assert.Equal(t, rw.Body.String(), "<div>Hello x</div>")
+
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLCached(rw, req, 200, "base/hello", "x")
+
+	assert.Equal(t, rw.Code, 304)
+	assert.Equal(t, rw.Header().Get("ETag"), etag)
+	assert.Equal(t, rw.Body.String(), "")
+
+	req.Header.Set("If-None-Match", "W/"+etag)
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLCached(rw, req, 200, "base/hello", "x")
+	assert.Equal(t, rw.Code, 304)
+
+	// changed page
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLCached(rw, req, 200, "base/hello", "y")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Body.String(), "<div>Hello y</div>")
+
+	// other methods and statuses are not tagged
+	post, _ := http.NewRequest("POST", "/", nil)
+	post.Header.Set("If-None-Match", etag)
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLCached(rw, post, 200, "base/hello", "x")
+	assert.Equal(t, rw.Code, 200)
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLCached(rw, req, 404, "base/hello", "x")
+	assert.Equal(t, rw.Code, 404)
+	assert.Equal(t, rw.Header().Get("ETag"), "")
+}
+
+func Test_CriticalCSS(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "critical/layout",
+	})
+
+	html, err := r.Copy().HTML("base/hello", "css")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<head><style>body { margin: 0; }</style></head>\n<div>Hello css</div>")
+	assert.Equal(t, r.css["critical/home"], template.CSS("body { margin: 0; }"))
+}
+
+func Test_PartialIsolated(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	binding := map[string]interface{}{"Name": "admin", "Token": "s3cr3t"}
+
+	html, err := r.Copy().HTML("isolated/page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "[admin|s3cr3t]\n[guest|]")
+}
+
+func Test_SetTheme(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+	binding := map[string]string{"Title": "Home"}
+
+	html, err := r.Copy().HTML("themed/home", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<body class="light">Home</body>`)
+
+	html, err = r.Copy().SetTheme("theme-dark").HTML("themed/home", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<body class="dark">Home</body>`)
+
+	// falls back to the base template
+	html, err = r.Copy().SetTheme("theme-dark").HTML("themed/about", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "about Home")
+}
+
+func Test_SetLocal(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	html, err := r.Copy().SetLocal("user", "<admin>").HTML("locals/page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><aside>Hi &lt;admin&gt;</aside></main>")
+
+	// locals don't leak to other copies
+	html, err = r.Copy().HTML("locals/page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main><aside>Guest</aside></main>")
+}
+
+func Test_SetVariants(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	html, err := r.Copy().SetVariants(map[string]string{"homepage-hero": "video"}).HTML("ab/hero", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<video></video>")
+
+	html, err = r.Copy().SetVariants(map[string]string{"homepage-hero": "image"}).HTML("ab/hero", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<img>")
+
+	// control without assignment
+	html, err = r.Copy().HTML("ab/hero", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<img>")
+}
+
+func Test_PartialSchemas(t *testing.T) {
+	schemas := map[string][]PropSpec{
+		"slots/card": {{Name: "body", Required: true, Type: "template.HTML"}, {Name: "title", Type: "string"}},
+	}
+	r := New(Options{
+		Directory:      "fixtures",
+		PartialSchemas: schemas,
+	})
+
+	html, err := r.Copy().HTML("slots/page", map[string]string{"Text": "hi"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<div class="card"><p>hi</p></div>`)
+
+	schemas["slots/card"] = append(schemas["slots/card"], PropSpec{Name: "footer", Required: true})
+	_, err = r.Copy().HTML("slots/page", map[string]string{"Text": "hi"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `partial "slots/card" is missing required prop "footer"`)
+
+	schemas["slots/card"] = []PropSpec{{Name: "body", Required: true, Type: "string"}}
+	_, err = r.Copy().HTML("slots/page", map[string]string{"Text": "hi"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `partial "slots/card" prop "body" must be string, got template.HTML`)
+}
+
+func Test_CachedPartial(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ cachedPartial "nav" "5m" "nav" "user" . }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_nav.html.tmpl"), []byte(`<nav>{{ .user }}</nav>`), 0644)
+
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	prod := false
+	r := New(Options{
+		Directory: dir,
+		DevMode:   &prod,
+	})
+	counter := new(renderCounter)
+
+	// miss
+	html, err := r.Copy().HTML("page", counter)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<nav>lazy</nav>")
+	assert.Equal(t, int(*counter), 1)
+
+	// hit
+	now = now.Add(4 * time.Minute)
+	html, _ = r.Copy().HTML("page", counter)
+	assert.Equal(t, html.String(), "<nav>lazy</nav>")
+	assert.Equal(t, int(*counter), 1)
+
+	// expired
+	now = now.Add(2 * time.Minute)
+	html, _ = r.Copy().HTML("page", counter)
+	assert.Equal(t, html.String(), "<nav>lazy</nav>")
+	assert.Equal(t, int(*counter), 2)
+
+	ioutil.WriteFile(filepath.Join(dir, "bad.html.tmpl"), []byte(`{{ cachedPartial "nav" 5 "nav" }}`), 0644)
+	r = New(Options{
+		Directory: dir,
+		DevMode:   &prod,
+	})
+	_, err = r.Copy().HTML("bad", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "ttl should be a string or time.Duration, got int")
+}
+
+func Test_PartialRaw(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	html, err := r.Copy().HTML("raw/page", map[string]interface{}{"Name": "<i>x</i>"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<pre>{"tag": "<b><i>x</i></b>", "ok": 1 > 0}</pre>`)
+}
+
+func Test_Escapers(t *testing.T) {
+	latex := strings.NewReplacer(`\`, `\textbackslash{}`, `{`, `\{`, `}`, `\}`, `&`, `\&`, `%`, `\%`)
+
+	r := New(Options{
+		Directory: "fixtures",
+		Escapers:  map[string]func(string) string{"latex": latex.Replace},
+	})
+
+	buf, err := r.Copy().RenderFormat("latex", "latex/doc", map[string]string{"Title": `50% {off} & <more>`})
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `\section{50\% \{off\} \& <more>}`)
+
+	// esc is identity without an escaper
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "note.txt.tmpl"), []byte(`{{ esc .Title }}`), 0644)
+
+	r = New(Options{
+		Directory: dir,
+		Escapers:  map[string]func(string) string{"latex": latex.Replace},
+	})
+
+	buf, err = r.Copy().RenderFormat("txt", "note", map[string]string{"Title": `50% {off}`})
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), `50% {off}`)
+}
+
+func Test_TextFormats(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "export.csv.tmpl"), []byte(`name,note{{ range . }}
+{{ .Name }},{{ .Note }}{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "welcome.txt.tmpl"), []byte(`Hi {{ .Name }} & welcome!`), 0644)
+
+	rows := []map[string]string{{"Name": "O'Brien", "Note": "<b>vip</b> & co"}}
+
+	r := New(Options{Directory: dir})
+	buf, err := r.Copy().RenderFormat("csv", "export", rows)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "name,note\nO&#39;Brien,&lt;b&gt;vip&lt;/b&gt; &amp; co")
+
+	r = New(Options{Directory: dir, TextFormats: []string{"csv"}})
+	buf, err = r.Copy().RenderFormat("csv", "export", rows)
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "name,note\nO'Brien,<b>vip</b> & co")
+
+	buf, err = r.Copy().Text("welcome", rows[0])
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "Hi O'Brien & welcome!")
+}
+
+func Test_MaxPartialDepth(t *testing.T) {
+	r := New(Options{
+		Directory:       "fixtures",
+		MaxPartialDepth: 10,
+	})
+
+	_, err := r.Copy().HTML("recursive/_loop", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `partial "recursive/loop" exceeded max depth of 10`)
+
+	r = New(Options{
+		Directory: "fixtures",
+	})
+
+	_, err = r.Copy().HTML("recursive/_loop", nil)
+	assert.Contains(t, err.Error(), "exceeded max depth of 50")
+
+	html, err := r.Copy().HTML("isolated/page", map[string]string{"Name": "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "[a|]\n[guest|]")
+}
+
+func Test_MissingKey(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ .Name }}{{ partial "card" . }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.tmpl"), []byte(`[{{ .Title }}]`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt.tmpl"), []byte(`{{ .Name }}: {{ .Title }}`), 0644)
+
+	binding := map[string]interface{}{"Name": "a"}
+
+	r := New(Options{Directory: dir, Layout: "layout"})
+	html, err := r.Copy().HTML("page", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main>a[]</main>")
+
+	txt, err := r.Copy().Text("notes", binding)
+	assert.Nil(t, err)
+	assert.Equal(t, txt.String(), "a: <no value>")
+
+	r = New(Options{Directory: dir, Layout: "layout", MissingKey: "zero"})
+	txt, err = r.Copy().Text("notes", map[string]string{"Name": "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, txt.String(), "a: ")
+
+	r = New(Options{Directory: dir, Layout: "layout", MissingKey: "error"})
+	_, err = r.Copy().HTML("page", binding)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `map has no entry for key "Title"`)
+
+	_, err = r.Copy().Text("notes", binding)
+	assert.NotNil(t, err)
+
+	html, err = r.Copy().HTML("page", map[string]interface{}{"Name": "a", "Title": "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<main>a[b]</main>")
+
+	_, err = NewE(Options{Directory: dir, MissingKey: "panic"})
+	assert.NotNil(t, err)
+}
+
+func Test_SecondPass(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "twopass/layout",
+		SecondPass: func(content []byte, binding interface{}) interface{} {
+			return map[string]interface{}{"Length": len(content), "Binding": binding}
+		},
+	})
+
+	html, err := r.Copy().HTML("base/hello", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "18:<div>Hello x</div>")
+}
+
+func Test_DefaultSourceFormat(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	assert.NotNil(t, r.t.Lookup("noformat/home"))
+	assert.Nil(t, r.t.Lookup("noformat/home.html"))
+
+	r = New(Options{
+		Directory:           "fixtures",
+		DefaultSourceFormat: "html",
+	})
+
+	assert.Nil(t, r.t.Lookup("noformat/home"))
+	assert.NotNil(t, r.t.Lookup("base/hello.html"))
+
+	html, err := r.Copy().HTML("noformat/home", "single format")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<p>single format</p>")
+}
+
+type renderCounter int
+
+func (c *renderCounter) String() string {
+	*c++
+	return "lazy"
+}
+
+func Test_WriterTo(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	counter := new(renderCounter)
+
+	w, err := r.Copy().Renderer("html", "base/hello", counter)
+	assert.Nil(t, err)
+	assert.Equal(t, int(*counter), 0)
+
+	buf := new(bytes.Buffer)
+	n, err := w.WriteTo(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int(*counter), 1)
+	assert.Equal(t, buf.String(), "head\n<div>Hello lazy</div>\nfoot")
+	assert.Equal(t, n, int64(buf.Len()))
+
+	_, err = r.Copy().Renderer("html", "base/missing", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+
+	// templates of the theme only
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "dark"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "dark", "promo.html.tmpl"), []byte(`dark promo`), 0644)
+
+	r = New(Options{Directory: dir})
+	w, err = r.Copy().SetTheme("dark").Renderer("html", "promo", nil)
+	assert.Nil(t, err)
+	buf.Reset()
+	w.WriteTo(buf)
+	assert.Equal(t, buf.String(), "dark promo")
+
+	_, err = r.Copy().Renderer("html", "promo", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+}
+
+func Test_RenderTee(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	counter := new(renderCounter)
+	rw := httptest.NewRecorder()
+	audit := new(bytes.Buffer)
+
+	err := r.Copy().RenderTee(rw, audit, "html", "base/hello", counter)
+	assert.Nil(t, err)
+	assert.Equal(t, int(*counter), 1)
+	assert.Equal(t, rw.Body.String(), "head\n<div>Hello lazy</div>\nfoot")
+	assert.Equal(t, audit.String(), rw.Body.String())
+
+	audit.Reset()
+	err = r.Copy().RenderTee(rw, audit, "html", "base/missing", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, audit.Len(), 0)
+}
+
+// writeCounter counts Write calls
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func Test_RenderTo(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	w := new(writeCounter)
+	assert.Nil(t, r.Copy().RenderTo(w, "html", "base/hello", "<world>"))
+	assert.Equal(t, w.String(), "head\n<div>Hello &lt;world&gt;</div>\nfoot")
+	assert.True(t, w.writes > 1)
+
+	binding := map[string]interface{}{"Package": "models", "Name": "User"}
+	expected, _ := r.Copy().RenderFormat("go", "gen/model", binding)
+	w = new(writeCounter)
+	assert.Nil(t, r.Copy().RenderTo(w, "go", "gen/model", binding))
+	assert.Equal(t, w.String(), expected.String())
+
+	// html without layout is buffered
+	w = new(writeCounter)
+	assert.Nil(t, r.Copy().SetLayout("").RenderTo(w, "html", "base/hello", "world"))
+	assert.Equal(t, w.String(), "<div>Hello world</div>")
+	assert.Equal(t, w.writes, 1)
+
+	w = new(writeCounter)
+	assert.NotNil(t, r.Copy().RenderTo(w, "html", "base/missing", nil))
+
+	r = New(Options{
+		Directory:     "fixtures",
+		Layout:        "base/layout",
+		NormalizeHTML: true,
+	})
+	w = new(writeCounter)
+	assert.Nil(t, r.Copy().RenderTo(w, "html", "base/hello", "world"))
+	assert.Equal(t, w.String(), "head<div>Hello world</div>foot")
+	assert.Equal(t, w.writes, 1)
+}
+
+func Test_Maintenance(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	r.SetMaintenance("base/maintenance")
+
+	html, err := r.Copy().HTML("base/hello", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<h1>Back soon</h1>")
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteJS(rw, 200, "base/hello", nil)
+	assert.Equal(t, rw.Code, 503)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+	assert.Equal(t, rw.Body.String(), "<h1>Back soon</h1>")
+
+	// data helpers send the maintenance page too
+	for _, write := range []func(rw *httptest.ResponseRecorder){
+		func(rw *httptest.ResponseRecorder) { r.Copy().WriteJSON(rw, 200, map[string]int{"id": 1}) },
+		func(rw *httptest.ResponseRecorder) { r.Copy().WriteJSONP(rw, 200, "cb", map[string]int{"id": 1}) },
+		func(rw *httptest.ResponseRecorder) { r.Copy().WriteXMLData(rw, 200, struct{ ID int }{1}) },
+	} {
+		rw = httptest.NewRecorder()
+		write(rw)
+		assert.Equal(t, rw.Code, 503)
+		assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+		assert.Equal(t, rw.Body.String(), "<h1>Back soon</h1>")
+	}
+
+	r.ClearMaintenance()
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteJSON(rw, 200, map[string]int{"id": 1})
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Header().Get(ContentType), ContentJSON)
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "base/hello", "x")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Body.String(), "<div>Hello x</div>")
+}
+
+func Test_RenderLayout(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	html, err := r.Copy().RenderLayout("base/layout", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head\n\nfoot")
+
+	r = New(Options{
+		Directory:   "fixtures",
+		StrictYield: true,
+	})
+
+	_, err = r.Copy().RenderLayout("base/layout", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "yield called without layout")
+}
+
+func Test_AllowedFuncs(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ slugify .Title }} {{ partial "card" "title" (upper .Title) }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_card.html.tmpl"), []byte(`[{{ .title }}]`), 0644)
+
+	opts := Options{
+		Directory:    dir,
+		Funcs:        []template.FuncMap{{"upper": strings.ToUpper}},
+		AllowedFuncs: []string{"slugify", "upper"},
+	}
+
+	r, err := newRenderer(opts)
+	assert.Nil(t, err)
+	html, err := r.Copy().HTML("page", map[string]string{"Title": "Hello World"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "hello-world [HELLO WORLD]")
+
+	ioutil.WriteFile(filepath.Join(dir, "secret.html.tmpl"), []byte(`{{ env "DATABASE_URL" }}`), 0644)
+
+	_, err = newRenderer(opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `"env" is not in Options.AllowedFuncs`)
+
+	// built-in helpers other than the layout ones must be listed too
+	os.Remove(filepath.Join(dir, "secret.html.tmpl"))
+	ioutil.WriteFile(filepath.Join(dir, "cached.html.tmpl"), []byte(`{{ cachedPartial "k" 60 "card" "title" .Title }}`), 0644)
+
+	_, err = newRenderer(opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `"cachedPartial" is not in Options.AllowedFuncs`)
+
+	opts.AllowedFuncs = append(opts.AllowedFuncs, "cachedPartial")
+	_, err = newRenderer(opts)
+	assert.Nil(t, err)
+}
+
+func Test_NewE(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users", "show.html.tmpl")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, []byte("<h1>{{ .Name }}</h1>\n<p>{{ .Email }</p>\n"), 0644)
+
+	r, err := NewE(Options{Directory: dir})
+	assert.Nil(t, r)
+	assert.NotNil(t, err)
+
+	var parseErr *ParseError
+	assert.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, parseErr.Path, path)
+	assert.Equal(t, parseErr.Line, 2)
+	assert.True(t, strings.HasPrefix(err.Error(), "wutrender: "+path+":2: "))
+
+	ioutil.WriteFile(path, []byte("<h1>{{ .Name }}</h1>\n"), 0644)
+
+	r, err = NewE(Options{Directory: dir})
+	assert.Nil(t, err)
+	html, _ := r.Copy().HTML("users/show", map[string]string{"Name": "bob"})
+	assert.Equal(t, html.String(), "<h1>bob</h1>\n")
+}
+
+func Test_NotFoundErrors(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	_, err := r.Copy().HTML("base/missing", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+	assert.Contains(t, err.Error(), `"base/missing.html"`)
+
+	_, err = r.Copy().RenderFormat("txt", "base/missing", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+
+	_, _, err = r.Copy().RenderPreferred([]string{"json", "xml"}, "base/hello", nil)
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+
+	assert.True(t, errors.Is(r.Copy().RenderTo(new(bytes.Buffer), "html", "base/missing", nil), ErrTemplateNotFound))
+
+	_, err = r.Copy().SetLayout("base/missing").HTML("base/hello", "world")
+	assert.True(t, errors.Is(err, ErrLayoutNotFound))
+	assert.False(t, errors.Is(err, ErrTemplateNotFound))
+
+	assert.True(t, errors.Is(r.Copy().SetLayout("base/missing").RenderTo(new(bytes.Buffer), "html", "base/hello", nil), ErrLayoutNotFound))
+
+	// a missing partial of an existing page is not a missing page
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ partial "missing" }}`), 0644)
+
+	_, err = New(Options{Directory: dir}).Copy().HTML("page", nil)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrTemplateNotFound))
+}
+
+func Test_UnusedTemplates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"layout.html.tmpl":  `<body>{{ yield }}</body>`,
+		"home.html.tmpl":    `{{ partial "nav" }}home`,
+		"_nav.html.tmpl":    `<nav></nav>`,
+		"_footer.html.tmpl": `<footer></footer>`,
+		"about.html.tmpl":   `about`,
+		"robots.txt.tmpl":   `User-agent: *`,
+		"critical.css.tmpl": `body {}`,
+	}
+	for name, text := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644)
+	}
+
+	r := New(Options{
+		Directory:  dir,
+		Layout:     "layout",
+		TrackUsage: true,
+	})
+
+	html, err := r.Copy().HTML("home", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<body><nav></nav>home</body>")
+	r.Copy().RenderFormat("txt", "robots", nil)
+
+	assert.Equal(t, r.UnusedTemplates(), []string{"_footer.html", "about.html", "critical.css"})
+}
+
+func Test_LayoutWithoutYield(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "noyield/layout",
+	})
+
+	_, err := r.Copy().HTML("base/hello", "x")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `layout "noyield/layout.html" did not yield content of "base/hello.html"`)
+
+	r = New(Options{
+		Directory:      "fixtures",
+		Layout:         "noyield/layout",
+		LayoutRegistry: map[string]string{},
+	})
+
+	_, err = r.Copy().HTML("base/hello", "x")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "did not yield content")
+}
+
+func Test_Directories(t *testing.T) {
+	base, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(base)
+	theme, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(theme)
+	os.Mkdir(filepath.Join(theme, "shared"), 0755)
+	os.Mkdir(filepath.Join(base, "shared"), 0755)
+	ioutil.WriteFile(filepath.Join(base, "layout.html.tmpl"), []byte(`<body class="{{ template "theme" }}">{{ yield }}</body>{{ define "theme" }}light{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(base, "page.html.tmpl"), []byte(`{{ partial "shared/nav" }}page`), 0644)
+	ioutil.WriteFile(filepath.Join(base, "shared", "_nav.html.tmpl"), []byte(`<nav>base</nav>`), 0644)
+	ioutil.WriteFile(filepath.Join(theme, "shared", "_nav.html.tmpl"), []byte(`<nav>dark</nav>`), 0644)
+	ioutil.WriteFile(filepath.Join(theme, "colors.html.tmpl"), []byte(`{{ define "theme" }}dark{{ end }}`), 0644)
+
+	r := New(Options{
+		Directories: []string{base, theme},
+		Layout:      "layout",
+	})
+
+	html, err := r.Copy().HTML("page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<body class="dark"><nav>dark</nav>page</body>`)
+
+	r = New(Options{
+		Directories: []string{theme, base},
+		Layout:      "layout",
+	})
+
+	html, err = r.Copy().HTML("page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<body class="light"><nav>base</nav>page</body>`)
+}
+
+func Test_ExtendsLayout(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "layouts"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "base.html.tmpl"), []byte(`<head>{{ yield "head" }}</head><body>{{ yield }}</body>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "admin.html.tmpl"), []byte(
+		`{{ extends "layouts/base" }}{{ contentFor "head" }}<admin>{{ yield "head" }}{{ endContentFor }}<nav></nav>{{ yield }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "bare.html.tmpl"), []byte(`{{ extends "layouts/base" }}<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "lost.html.tmpl"), []byte(`{{ extends "layouts/base" }}lost`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "loop.html.tmpl"), []byte(`{{ extends "layouts/loop" }}{{ yield }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "layouts", "orphan.html.tmpl"), []byte(`{{ extends "layouts/missing" }}{{ yield }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ contentFor "head" }}<css>{{ endContentFor }}page {{ . }}`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Layout:    "layouts/admin",
+	})
+
+	html, err := r.Copy().HTML("page", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<head><admin><css></head><body><nav></nav>page x</body>")
+
+	buf := new(bytes.Buffer)
+	err = r.Copy().RenderTo(buf, "html", "page", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "<head><admin><css></head><body><nav></nav>page x</body>")
+
+	html, err = r.Copy().SetLayout("layouts/bare").HTML("page", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<head><css></head><body><main>page x</main></body>")
+
+	html, err = r.Copy().RenderLayout("layouts/bare", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<head></head><body><main></main></body>")
+
+	_, err = r.Copy().SetLayout("layouts/lost").HTML("page", "x")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `layout "layouts/lost.html" did not yield content of "page.html"`)
+
+	_, err = r.Copy().SetLayout("layouts/loop").HTML("page", "x")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is there a cycle?")
+
+	_, err = r.Copy().SetLayout("layouts/orphan").HTML("page", "x")
+	assert.True(t, errors.Is(err, ErrLayoutNotFound))
+
+	err = r.Verify()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `layouts/orphan.html: extends "layouts/missing"`)
+}
+
+func Test_RenderBlocks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<body>{{ yield }}</body>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "shop.html.tmpl"), []byte(
+		`<h1>Shop</h1>{{ block "cart" . }}<b>{{ len .Items }} items</b>{{ end }}{{ template "banner" . }}`+
+			`{{ define "banner" }}<i>{{ .Sale }}</i>{{ end }}{{ define "unused" }}x{{ end }}`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Layout:    "layout",
+	})
+	binding := map[string]interface{}{"Items": []string{"a", "b"}, "Sale": "-20%"}
+
+	blocks, err := r.Copy().RenderBlocks("shop", binding, []string{"cart", "banner"})
+	assert.Nil(t, err)
+	assert.Equal(t, len(blocks), 2)
+	assert.Equal(t, blocks["cart"].String(), "<b>2 items</b>")
+	assert.Equal(t, blocks["banner"].String(), "<i>-20%</i>")
+
+	_, err = r.Copy().RenderBlocks("shop", binding, []string{"unused"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `template "shop.html" has no block "unused"`)
+
+	_, err = r.Copy().RenderBlocks("missing", binding, []string{"cart"})
+	assert.NotNil(t, err)
+}
+
+func Test_WriteHTMLType(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLType(rw, 201, "base/hello", "x", "application/xhtml+xml")
+	assert.Equal(t, rw.Code, 201)
+	assert.Equal(t, rw.Header().Get(ContentType), "application/xhtml+xml")
+	assert.Equal(t, rw.Body.String(), "<div>Hello x</div>")
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLType(rw, 200, "base/hello", "x", "")
+	assert.Equal(t, rw.Header().Get(ContentType), ContentHTML)
+}
+
+func Test_RenderPreferred(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	format, html, err := r.Copy().RenderPreferred([]string{"json", "html"}, "base/hello", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, format, "html")
+	assert.Equal(t, html.String(), "<div>Hello x</div>")
+
+	format, _, err = r.Copy().RenderPreferred([]string{"css", "html"}, "critical/home", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, format, "css")
+
+	format, _, err = r.Copy().RenderPreferred([]string{"json", "xml"}, "base/hello", nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, format, "")
+
+	// the json template of the theme is preferred over the html one
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "dark"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "dark", "promo.json.tmpl"), []byte(`{"theme":"dark"}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "promo.html.tmpl"), []byte(`promo`), 0644)
+
+	r = New(Options{Directory: dir})
+	format, json, err := r.Copy().SetTheme("dark").RenderPreferred([]string{"json", "html"}, "promo", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, format, "json")
+	assert.Equal(t, json.String(), `{"theme":"dark"}`)
+
+	format, _, err = r.Copy().RenderPreferred([]string{"json", "html"}, "promo", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, format, "html")
+}
+
+func Test_RequestID(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	first := r.Copy().SetRequestID("req-1")
+	second := r.Copy().SetRequestID("req-2")
+
+	html, err := first.HTML("base/rid", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<meta name="request-id" content="req-1">`)
+
+	html, _ = second.HTML("base/rid", nil)
+	assert.Equal(t, html.String(), `<meta name="request-id" content="req-2">`)
+
+	html, _ = r.Copy().HTML("base/rid", nil)
+	assert.Equal(t, html.String(), `<meta name="request-id" content="">`)
+}
+
+func Test_LayoutRegistry(t *testing.T) {
+	r := New(Options{
+		Directory:      "fixtures",
+		Layout:         "base/layout",
+		LayoutRegistry: map[string]string{"admin": "registry/admin"},
+	})
+
+	html, err := r.Copy().HTML("registry/page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "admin[page]")
+
+	html, err = r.Copy().HTML("base/hello", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head\n<div>Hello x</div>\nfoot")
+
+	html, err = r.Copy().SetLayout("").HTML("registry/page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "admin[page]")
+
+	_, err = r.Copy().HTML("registry/unknown", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `layout "nope" is not in Options.LayoutRegistry`)
+}
+
+func Test_HTMLWithLayout(t *testing.T) {
+	r := New(Options{
+		Directory:      "fixtures",
+		Layout:         "base/layout",
+		LayoutRegistry: map[string]string{"admin": "registry/admin"},
+	})
+
+	tmpl := r.Copy()
+	html, err := tmpl.HTMLWithLayout("", "base/hello", "bare")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<div>Hello bare</div>")
+
+	html, err = tmpl.HTMLWithLayout("registry/admin", "base/hello", "admin")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "admin[<div>Hello admin</div>]")
+
+	// only for one render
+	html, err = tmpl.HTML("base/hello", "page")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head\n<div>Hello page</div>\nfoot")
+
+	// useLayout doesn't override the caller
+	html, err = tmpl.HTMLWithLayout("", "registry/page", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "page")
+
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLWithLayout(rw, 200, "", "base/hello", "bare")
+	assert.Equal(t, rw.Code, 200)
+	assert.Equal(t, rw.Body.String(), "<div>Hello bare</div>")
+
+	rw = httptest.NewRecorder()
+	r.Copy().WriteHTMLWithLayout(rw, 200, "missing", "base/hello", "bare")
+	assert.Equal(t, rw.Code, 500)
+}
+
+func Test_StreamEach(t *testing.T) {
+	items := func(values ...interface{}) <-chan interface{} {
+		ch := make(chan interface{})
+		go func() {
+			for _, v := range values {
+				ch <- v
+			}
+			close(ch)
+		}()
+		return ch
+	}
+
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	rw := httptest.NewRecorder()
+	err := r.Copy().StreamEach(rw, "stream/row", items(map[string]string{"Name": "a"}, map[string]string{"Name": "b"}))
+	assert.Nil(t, err)
+	assert.Equal(t, rw.Body.String(), "<tr>a</tr><tr>b</tr>")
+	assert.True(t, rw.Flushed)
+
+	buf := new(bytes.Buffer)
+	ch := items(map[string]string{"Name": "a"}, 42, map[string]string{"Name": "c"})
+	err = r.Copy().StreamEach(buf, "stream/row", ch)
+	assert.NotNil(t, err)
+	assert.Equal(t, buf.String(), "<tr>a</tr>")
+	for range ch {
+	}
+
+	r = New(Options{
+		Directory:        "fixtures",
+		StreamSkipErrors: true,
+	})
+
+	buf.Reset()
+	err = r.Copy().StreamEach(buf, "stream/row", items(map[string]string{"Name": "a"}, 42, map[string]string{"Name": "c"}))
+	assert.Nil(t, err)
+	assert.Equal(t, buf.String(), "<tr>a</tr><tr>c</tr>")
+}
+
+func Test_OnMissingTemplate(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		OnMissingTemplate: func(name, format string) (*bytes.Buffer, error) {
+			if strings.HasPrefix(name, "cms/") {
+				return bytes.NewBufferString("cms:" + name + "." + format), nil
+			}
+			return nil, ErrTemplateNotFound
+		},
+	})
+
+	html, err := r.Copy().HTML("cms/about", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "cms:cms/about.html")
+
+	html, err = r.Copy().HTML("base/hello", "x")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<div>Hello x</div>")
+
+	_, err = r.Copy().HTML("base/missing", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "base/missing.html")
+
+	// Renderer leaves missing templates to OnMissingTemplate
+	w, err := r.Copy().Renderer("html", "cms/about", nil)
+	assert.Nil(t, err)
+	buf := new(bytes.Buffer)
+	w.WriteTo(buf)
+	assert.Equal(t, buf.String(), "cms:cms/about.html")
+
+	w, err = r.Copy().Renderer("html", "base/missing", nil)
+	assert.Nil(t, err)
+	_, err = w.WriteTo(new(bytes.Buffer))
+	assert.True(t, errors.Is(err, ErrTemplateNotFound))
+}
+
+func Test_ReservedFuncs(t *testing.T) {
+	warnings := new(bytes.Buffer)
+
+	New(Options{
+		Directory: "fixtures",
+		Logger:    log.New(warnings, "", 0),
+		Funcs: []template.FuncMap{{
+			"yield":   func() string { return "" },
+			"partial": func() string { return "" },
+			"upper":   strings.ToUpper,
+		}},
+	})
+
+	assert.Equal(t, warnings.String(),
+		"wutrender: helper \"partial\" from Options.Funcs is reserved and will be replaced by the built-in one\n"+
+			"wutrender: helper \"yield\" from Options.Funcs is reserved and will be replaced by the built-in one\n")
+}
+
+func Test_RenderSlot(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+	})
+
+	html, err := r.Copy().HTML("slots/page", map[string]string{"Text": "<hi>"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<div class="card"><p>&lt;hi&gt;</p></div>`)
+}
+
+func Test_ReleaseBuffer(t *testing.T) {
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				text := fmt.Sprintf("<%d-%d>", i, j)
+
+				html, err := r.Copy().SetLayout("").HTML("slots/page", map[string]string{"Text": text})
+				assert.Nil(t, err)
+				assert.Equal(t, html.String(), `<div class="card"><p>&lt;`+text[1:len(text)-1]+`&gt;</p></div>`)
+				ReleaseBuffer(html)
+
+				rw := httptest.NewRecorder()
+				r.Copy().WriteHTML(rw, 200, "base/hello", text)
+				assert.Equal(t, rw.Body.String(), "head\n<div>Hello &lt;"+text[1:len(text)-1]+"&gt;</div>\nfoot")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ReleaseBuffer(nil)
+}
+
+func Test_ConcurrentRenders(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<main>{{ yield }}</main>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "admin.html.tmpl"), []byte(`<admin>{{ yield }}</admin>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "page.html.tmpl"), []byte(`{{ if .Admin }}{{ useLayout "admin" }}{{ end }}{{ partial "item" . }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_item.html.tmpl"), []byte(`<i>{{ .N }}</i>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "note.latex.tmpl"), []byte(`{{ esc .N }}`), 0644)
+
+	r := New(Options{
+		Directory:      dir,
+		Layout:         "layout",
+		LayoutRegistry: map[string]string{"admin": "admin"},
+		Escapers:       map[string]func(string) string{"latex": func(s string) string { return "[" + s + "]" }},
+	})
+
+	// one copy shared by goroutines
+	tmpl := r.Copy()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				n := i*100 + j
+				admin := n%2 == 0
+
+				html, err := tmpl.HTML("page", map[string]interface{}{"N": n, "Admin": admin})
+				assert.Nil(t, err)
+				if admin {
+					assert.Equal(t, html.String(), fmt.Sprintf("<admin><i>%d</i></admin>", n))
+				} else {
+					assert.Equal(t, html.String(), fmt.Sprintf("<main><i>%d</i></main>", n))
+				}
+
+				buf, err := tmpl.RenderFormat("latex", "note", map[string]int{"N": n})
+				assert.Nil(t, err)
+				assert.Equal(t, buf.String(), fmt.Sprintf("[%d]", n))
+
+				html, err = tmpl.RenderLayout("layout", nil)
+				assert.Nil(t, err)
+				assert.Equal(t, html.String(), "<main></main>")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// yield of a previous layout render doesn't leak into renders without layout
+	_, err := tmpl.SetLayout("").HTML("layout", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "yield called without layout")
+}
+
+func Test_Macros(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "macros.html.tmpl"), []byte(`{{ define "button" }}<button class="btn-{{ .Variant }}">{{ .Text }}</button>{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "form.html.tmpl"), []byte(`{{ macro "button" "Save" "primary" }}{{ macro "button" .Label "link" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "wrong.html.tmpl"), []byte(`{{ macro "button" "Save" }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "unknown.html.tmpl"), []byte(`{{ macro "link" "Save" }}`), 0644)
+
+	r := New(Options{
+		Directory: dir,
+		Macros:    map[string][]string{"button": {"Text", "Variant"}},
+	})
+
+	html, err := r.Copy().HTML("form", map[string]string{"Label": "<Cancel>"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), `<button class="btn-primary">Save</button><button class="btn-link">&lt;Cancel&gt;</button>`)
+
+	_, err = r.Copy().HTML("wrong", nil)
+	assert.Contains(t, err.Error(), `macro "button" takes 2 arguments [Text Variant], got 1`)
+
+	_, err = r.Copy().HTML("unknown", nil)
+	assert.Contains(t, err.Error(), `macro "link" is not in Options.Macros`)
+}
+
+func Test_DevMode(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "page.html.tmpl")
+	ioutil.WriteFile(file, []byte(`v1`), 0644)
+
+	dev, prod := true, false
+
+	devRenderer := New(Options{Directory: dir, DevMode: &dev})
+	prodRenderer := New(Options{Directory: dir, DevMode: &prod})
+
+	ioutil.WriteFile(file, []byte(`v2`), 0644)
+
+	html, _ := devRenderer.Copy().HTML("page", nil)
+	assert.Equal(t, html.String(), "v2")
+
+	html, _ = prodRenderer.Copy().HTML("page", nil)
+	assert.Equal(t, html.String(), "v1")
+}
+
+func Test_CopyFrom(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "base"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "base", "hello.html.tmpl"), []byte(`<div>Preview {{ . }}</div>`), 0644)
+
+	r := New(Options{
+		Directory: "fixtures",
+		Layout:    "base/layout",
+	})
+
+	tmpl, err := r.CopyFrom(dir)
+	assert.Nil(t, err)
+	html, err := tmpl.HTML("base/hello", "draft")
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "head\n<div>Preview draft</div>\nfoot")
+
+	// source templates are untouched
+	html, _ = r.Copy().HTML("base/hello", "live")
+	assert.Equal(t, html.String(), "head\n<div>Hello live</div>\nfoot")
+
+	ioutil.WriteFile(filepath.Join(dir, "broken.html.tmpl"), []byte(`{{ if }}`), 0644)
+	_, err = r.CopyFrom(dir)
+	assert.NotNil(t, err)
+}
+
+func Test_BaseRenderer(t *testing.T) {
+	baseDir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(baseDir)
+	os.Mkdir(filepath.Join(baseDir, "shared"), 0755)
+	ioutil.WriteFile(filepath.Join(baseDir, "shared", "_nav.html.tmpl"), []byte(`<nav>{{ shout . }}</nav>`), 0644)
+	ioutil.WriteFile(filepath.Join(baseDir, "shared", "layout.html.tmpl"), []byte(`base[{{ yield }}]`), 0644)
+	ioutil.WriteFile(filepath.Join(baseDir, "shared", "footer.html.tmpl"), []byte(`base footer`), 0644)
+
+	tenantDir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(tenantDir)
+	ioutil.WriteFile(filepath.Join(tenantDir, "page.html.tmpl"), []byte(`{{ partial "shared/nav" .Name }}`), 0644)
+	os.Mkdir(filepath.Join(tenantDir, "shared"), 0755)
+	ioutil.WriteFile(filepath.Join(tenantDir, "shared", "footer.html.tmpl"), []byte(`tenant footer`), 0644)
+
+	base := New(Options{
+		Directory: baseDir,
+		Funcs:     []template.FuncMap{{"shout": strings.ToUpper}},
+	})
+
+	tenant := New(Options{
+		Directory:    tenantDir,
+		Layout:       "shared/layout",
+		BaseRenderer: base,
+	})
+
+	html, err := tenant.Copy().HTML("page", map[string]string{"Name": "acme"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "base[<nav>ACME</nav>]")
+
+	html, _ = tenant.Copy().SetLayout("").HTML("shared/footer", nil)
+	assert.Equal(t, html.String(), "tenant footer")
+
+	html, _ = base.Copy().HTML("shared/footer", nil)
+	assert.Equal(t, html.String(), "base footer")
+	assert.Nil(t, base.t.Lookup("page.html"))
+}
+
+func Test_TemplateTimeouts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "slow.html.tmpl"), []byte(`{{ sleep }}slow`), 0644)
+
+	r := New(Options{
+		Directory:        dir,
+		Funcs:            []template.FuncMap{{"sleep": func() string { time.Sleep(200 * time.Millisecond); return "" }}},
+		TemplateTimeouts: map[string]time.Duration{"slow.html": 10 * time.Millisecond},
+	})
+
+	_, err := r.Copy().HTML("slow", nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), `rendering "slow.html" timed out after 10ms`)
+
+	r.options.TemplateTimeouts["slow.html"] = time.Second
+
+	html, err := r.Copy().HTML("slow", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "slow")
+
+	// the error page doesn't wait for the abandoned render
+	ioutil.WriteFile(filepath.Join(dir, "500.html.tmpl"), []byte(`oops`), 0644)
+	assert.Nil(t, r.Reload())
+	r.ErrorPages(map[int]string{500: "500"})
+	r.options.TemplateTimeouts["slow.html"] = 10 * time.Millisecond
+
+	start := time.Now()
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTML(rw, 200, "slow", nil)
+	assert.True(t, time.Since(start) < 150*time.Millisecond, time.Since(start))
+	assert.Equal(t, rw.Code, 500)
+	assert.Equal(t, rw.Body.String(), "oops")
+}
+
+func Test_RenderContext(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "layout.html.tmpl"), []byte(`<body>{{ yield }}</body>`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "list.html.tmpl"), []byte(`{{ range . }}{{ partial "row" "n" . }}{{ end }}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_row.html.tmpl"), []byte(`<tr>{{ tick .n }}</tr>`), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ticks := 0
+
+	r := New(Options{
+		Directory: dir,
+		Layout:    "layout",
+		Funcs: []template.FuncMap{{"tick": func(n int) int {
+			ticks++
+			if n == 2 {
+				cancel()
+			}
+			if n > 2 {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return n
+		}}},
+	})
+
+	html, err := r.Copy().HTMLContext(ctx, "list", []int{1, 2, 3, 4})
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, html.String(), "")
+	assert.Equal(t, ticks, 2)
+
+	// done before rendering
+	ticks = 0
+	_, err = r.Copy().HTMLContext(ctx, "list", []int{1})
+	assert.Equal(t, err, context.Canceled)
+	assert.Equal(t, ticks, 0)
+
+	html, err = r.Copy().HTMLContext(context.Background(), "list", []int{1})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<body><tr>1</tr></body>")
+
+	r.options.RenderTimeout = 30 * time.Millisecond
+	ticks = 0
+	_, err = r.Copy().HTML("list", []int{3, 3, 3, 3, 3})
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.True(t, ticks < 5)
+
+	// RenderTo is buffered to honor the deadline
+	ticks = 0
+	var w strings.Builder
+	err = r.Copy().RenderTo(&w, "html", "list", []int{3, 3, 3, 3, 3})
+	assert.Equal(t, err, context.DeadlineExceeded)
+	assert.Equal(t, w.String(), "")
+	assert.True(t, ticks < 5)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(ctx)
+	rw := httptest.NewRecorder()
+	r.Copy().WriteHTMLContext(rw, req, 200, "list", []int{1})
+	assert.Equal(t, rw.Code, 500)
+}
+
+func Test_ReloadDebounce(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "wutrender")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "page.html.tmpl")
+	ioutil.WriteFile(file, []byte(`v1`), 0644)
+
+	prod := false
+	r := New(Options{
+		Directory:      dir,
+		DevMode:        &prod,
+		ReloadDebounce: 50 * time.Millisecond,
+	})
+
+	// editor save burst
+	for i := 2; i <= 5; i++ {
+		ioutil.WriteFile(file, []byte(fmt.Sprintf("v%d", i)), 0644)
+		r.NotifyChange()
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	html, _ := r.Copy().HTML("page", nil)
+	assert.Equal(t, html.String(), "v1")
+
+	time.Sleep(150 * time.Millisecond)
+
+	r.mu.RLock()
+	assert.Equal(t, r.reloads, 1)
+	r.mu.RUnlock()
+
+	html, _ = r.Copy().HTML("page", nil)
+	assert.Equal(t, html.String(), "v5")
+}
+
+func Test_FS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"templates/layout.html.tmpl":      {Data: []byte(`<body>{{ yield }}</body>`)},
+		"templates/users/show.html.tmpl":  {Data: []byte(`{{ partial "users/card" . }}`)},
+		"templates/users/_card.html.tmpl": {Data: []byte(`<b>{{ .Name }}</b>`)},
+		"templates/users/show.txt.tmpl":   {Data: []byte(`{{ .Name }}`)},
+		"templates/users/notes.md":        {Data: []byte(`skipped`)},
+		"other/ignored.html.tmpl":         {Data: []byte(`x`)},
+	}
+
+	r := New(Options{
+		FS:     fsys,
+		Layout: "layout",
+	})
+	assert.Equal(t, r.UserTemplateCount(), 4)
+
+	html, err := r.Copy().HTML("users/show", map[string]string{"Name": "Ann"})
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "<body><b>Ann</b></body>")
+
+	txt, err := r.Copy().RenderFormat("txt", "users/show", map[string]string{"Name": "Ann"})
+	assert.Nil(t, err)
+	assert.Equal(t, txt.String(), "Ann")
+
+	// FS root
+	r = New(Options{
+		FS:        fsys,
+		Directory: ".",
+	})
+	html, err = r.Copy().HTML("other/ignored", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, html.String(), "x")
+
+	_, err = newRenderer(Options{FS: fsys, Directory: "missing"})
+	assert.NotNil(t, err)
+}